@@ -5,12 +5,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 
 	"github.com/adrianmcphee/smarterbase/internal/export"
+	"github.com/adrianmcphee/smarterbase/internal/pgimport"
 	"github.com/adrianmcphee/smarterbase/internal/protocol"
 	"github.com/adrianmcphee/smarterbase/internal/storage"
 )
@@ -22,6 +24,9 @@ func main() {
 		case "export":
 			runExport(os.Args[2:])
 			return
+		case "import":
+			runImport(os.Args[2:])
+			return
 		case "help", "--help", "-h":
 			printHelp()
 			return
@@ -38,21 +43,45 @@ func printHelp() {
 Usage:
   smarterbase [flags]              Start the server
   smarterbase export [flags]       Export schema and data to PostgreSQL
+  smarterbase import [flags]       Import rows into a table
 
 Server flags:
-  --port int     Port to listen on (default 5433)
-  --data string  Data directory (default "./data")
+  --port int               Port to listen on (default 5433)
+  --data string            Data directory (default "./data")
+  --auth-user string       Require clients to log in as this user; needs --tls-cert ($SMARTERBASE_AUTH_USER)
+  --auth-password string   Password for --auth-user ($SMARTERBASE_AUTH_PASSWORD)
+  --tls-cert string        TLS certificate file; clients must then use TLS
+  --tls-key string         TLS private key file
+  --lock-stripes int       Number of table lock stripes (default 16)
+  --fsync                  Flush each write to disk (durable, slower)
 
 Export flags:
-  --data string  Data directory (default "./data")
-  --ddl-only     Export only schema (no data)
-  --data-only    Export only data (no schema)`)
+  --data string    Data directory (default "./data")
+  --format string  Output format: sql, csv, json or ndjson (default "sql")
+  --table string   Table to export (required for csv; json/ndjson default to all)
+  --ddl-only       Export only schema (no data)
+  --data-only      Export only data (no schema)
+
+Import flags:
+  --data string       Data directory (default "./data")
+  --format string     Input format: csv (default "csv")
+  --table string      Table to import into (required)
+  --file string       Input file (default stdin)
+  --pg-dsn string     Import the table from PostgreSQL instead of a file
+  --pg-schema string  PostgreSQL schema to read from (default "public")
+  --batch-size int    Rows written per batch for --pg-dsn (default 1000)`)
 }
 
 func runServer() {
 	var (
-		port    = flag.Int("port", 5433, "Port to listen on")
-		dataDir = flag.String("data", "./data", "Data directory")
+		port         = flag.Int("port", 5433, "Port to listen on")
+		dataDir      = flag.String("data", "./data", "Data directory")
+		authUser     = flag.String("auth-user", os.Getenv("SMARTERBASE_AUTH_USER"), "Require clients to log in as this user")
+		authPassword = flag.String("auth-password", os.Getenv("SMARTERBASE_AUTH_PASSWORD"), "Password for --auth-user")
+		tlsCert      = flag.String("tls-cert", "", "TLS certificate file")
+		tlsKey       = flag.String("tls-key", "", "TLS private key file")
+		lockStripes  = flag.Int("lock-stripes", storage.DefaultLockStripes, "Number of table lock stripes")
+		fsync        = flag.Bool("fsync", false, "Flush each write to disk (durable, slower)")
 	)
 	flag.Parse()
 
@@ -66,7 +95,16 @@ func runServer() {
 	log.Printf("SmarterBase starting...")
 	log.Printf("Data directory: %s", *dataDir)
 
-	server, err := protocol.NewServer(*port, *dataDir)
+	server, err := protocol.NewServerWithOptions(*port, *dataDir, protocol.Options{
+		AuthUser:     *authUser,
+		AuthPassword: *authPassword,
+		TLSCertFile:  *tlsCert,
+		TLSKeyFile:   *tlsKey,
+		Store: storage.StoreOptions{
+			LockStripes: *lockStripes,
+			Fsync:       *fsync,
+		},
+	})
 	if err != nil {
 		log.Fatalf("Failed to create server: %v", err)
 	}
@@ -79,6 +117,8 @@ func runServer() {
 func runExport(args []string) {
 	exportFlags := flag.NewFlagSet("export", flag.ExitOnError)
 	dataDir := exportFlags.String("data", "./data", "Data directory")
+	format := exportFlags.String("format", "sql", "Output format: sql, csv, json or ndjson")
+	table := exportFlags.String("table", "", "Table to export (required for csv; json/ndjson default to all)")
 	ddlOnly := exportFlags.Bool("ddl-only", false, "Export only schema (no data)")
 	dataOnly := exportFlags.Bool("data-only", false, "Export only data (no schema)")
 	exportFlags.Parse(args)
@@ -89,16 +129,106 @@ func runExport(args []string) {
 		log.Fatalf("Failed to open data directory: %v", err)
 	}
 
-	// Generate export
-	var output string
-	switch {
-	case *ddlOnly:
-		output = export.ExportDDL(store)
-	case *dataOnly:
-		output = export.ExportData(store)
+	switch *format {
+	case "sql":
+		// Generate export
+		var output string
+		switch {
+		case *ddlOnly:
+			output = export.ExportDDL(store)
+		case *dataOnly:
+			output = export.ExportData(store)
+		default:
+			output = export.Export(store)
+		}
+
+		fmt.Print(output)
+
+	case "csv":
+		if *table == "" {
+			log.Fatalf("--table is required for csv export")
+		}
+		if err := export.ExportCSV(store, *table, os.Stdout); err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+
+	case "json", "ndjson":
+		var tables []string
+		if *table != "" {
+			tables = []string{*table}
+		}
+
+		write := export.ExportNDJSON
+		if *format == "json" {
+			write = export.ExportJSON
+		}
+		if err := write(store, tables, os.Stdout); err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+
 	default:
-		output = export.Export(store)
+		log.Fatalf("Unknown export format: %s", *format)
+	}
+}
+
+func runImport(args []string) {
+	importFlags := flag.NewFlagSet("import", flag.ExitOnError)
+	dataDir := importFlags.String("data", "./data", "Data directory")
+	format := importFlags.String("format", "csv", "Input format: csv")
+	table := importFlags.String("table", "", "Table to import into (required)")
+	file := importFlags.String("file", "", "Input file (default stdin)")
+	pgDSN := importFlags.String("pg-dsn", "", "Import the table from PostgreSQL instead of a file")
+	pgSchema := importFlags.String("pg-schema", "public", "PostgreSQL schema to read from")
+	batchSize := importFlags.Int("batch-size", 1000, "Rows written per batch for --pg-dsn")
+	importFlags.Parse(args)
+
+	if *table == "" {
+		log.Fatalf("--table is required")
+	}
+	if *pgDSN == "" && *format != "csv" {
+		log.Fatalf("Unknown import format: %s", *format)
+	}
+
+	// Ensure data directory exists
+	if err := os.MkdirAll(*dataDir, 0755); err != nil {
+		log.Fatalf("Failed to create data directory: %v", err)
+	}
+
+	// Open the store
+	store, err := storage.NewStore(*dataDir)
+	if err != nil {
+		log.Fatalf("Failed to open data directory: %v", err)
+	}
+
+	if *pgDSN != "" {
+		stats, err := pgimport.Import(context.Background(), store, pgimport.Options{
+			DSN:       *pgDSN,
+			Schema:    *pgSchema,
+			Table:     *table,
+			BatchSize: *batchSize,
+		})
+		if err != nil {
+			log.Fatalf("Import failed after %d rows: %v", stats.Imported, err)
+		}
+
+		fmt.Fprintf(os.Stderr, "Imported %d rows into %s (%d already present)\n",
+			stats.Imported, *table, stats.Skipped)
+		return
+	}
+
+	input := os.Stdin
+	if *file != "" {
+		input, err = os.Open(*file)
+		if err != nil {
+			log.Fatalf("Failed to open input: %v", err)
+		}
+		defer input.Close()
+	}
+
+	count, err := export.ImportCSV(store, *table, input)
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
 	}
 
-	fmt.Print(output)
+	fmt.Fprintf(os.Stderr, "Imported %d rows into %s\n", count, *table)
 }
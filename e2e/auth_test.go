@@ -0,0 +1,133 @@
+package e2e
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adrianmcphee/smarterbase/internal/protocol"
+	"github.com/jackc/pgx/v5"
+)
+
+// writeTestCert writes a self-signed certificate for localhost and returns
+// the cert and key file paths
+func writeTestCert(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+
+	return certFile, keyFile
+}
+
+// tryConnect connects with the given connection string options and runs a
+// query, returning the first error
+func (env *testEnv) tryConnect(options string) error {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, fmt.Sprintf("host=localhost port=%d %s", env.port, options))
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	var version string
+	return conn.QueryRow(ctx, "SELECT version()").Scan(&version)
+}
+
+func TestPasswordAuth(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+
+	env := setupTestWithOptions(t, protocol.Options{
+		AuthUser:     "app",
+		AuthPassword: "s3cret",
+		TLSCertFile:  certFile,
+		TLSKeyFile:   keyFile,
+	})
+	defer env.cleanup()
+
+	if err := env.tryConnect("user=app password=s3cret sslmode=require"); err != nil {
+		t.Errorf("Expected login to succeed: %v", err)
+	}
+	if err := env.tryConnect("user=app password=wrong sslmode=require"); err == nil {
+		t.Error("Expected wrong password to be rejected")
+	}
+	if err := env.tryConnect("user=other password=s3cret sslmode=require"); err == nil {
+		t.Error("Expected wrong user to be rejected")
+	}
+}
+
+func TestTLS(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+
+	env := setupTestWithOptions(t, protocol.Options{
+		AuthUser:     "app",
+		AuthPassword: "s3cret",
+		TLSCertFile:  certFile,
+		TLSKeyFile:   keyFile,
+	})
+	defer env.cleanup()
+
+	if err := env.tryConnect("user=app password=s3cret sslmode=require"); err != nil {
+		t.Errorf("Expected TLS login to succeed: %v", err)
+	}
+	if err := env.tryConnect("user=app password=s3cret sslmode=disable"); err == nil {
+		t.Error("Expected connection without TLS to be rejected")
+	}
+}
+
+func TestNewServerWithOptions_Invalid(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := protocol.NewServerWithOptions(nextPort(), dir, protocol.Options{AuthUser: "app"}); err == nil {
+		t.Error("Expected error for user without password")
+	}
+	// The password would otherwise cross the wire in cleartext
+	if _, err := protocol.NewServerWithOptions(nextPort(), dir, protocol.Options{
+		AuthUser:     "app",
+		AuthPassword: "s3cret",
+	}); err == nil {
+		t.Error("Expected error for password authentication without TLS")
+	}
+	if _, err := protocol.NewServerWithOptions(nextPort(), dir, protocol.Options{
+		TLSCertFile: filepath.Join(dir, "missing.pem"),
+		TLSKeyFile:  filepath.Join(dir, "missing.key"),
+	}); err == nil {
+		t.Error("Expected error for missing certificate")
+	}
+}
@@ -28,13 +28,17 @@ type testEnv struct {
 }
 
 func setupTest(t *testing.T) *testEnv {
+	return setupTestWithOptions(t, protocol.Options{})
+}
+
+func setupTestWithOptions(t *testing.T, opts protocol.Options) *testEnv {
 	port := nextPort()
 	dir := filepath.Join(os.TempDir(), fmt.Sprintf("smarterbase_test_%d", time.Now().UnixNano()))
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		t.Fatalf("Failed to create test dir: %v", err)
 	}
 
-	server, err := protocol.NewServer(port, dir)
+	server, err := protocol.NewServerWithOptions(port, dir, opts)
 	if err != nil {
 		t.Fatalf("Failed to create server: %v", err)
 	}
@@ -67,6 +71,17 @@ func (env *testEnv) connect(t *testing.T) *pgx.Conn {
 	return conn
 }
 
+// connectExtended connects using pgx's default extended query protocol,
+// so queries with $N parameters run as prepared statements
+func (env *testEnv) connectExtended(t *testing.T) *pgx.Conn {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, fmt.Sprintf("host=localhost port=%d sslmode=disable", env.port))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	return conn
+}
+
 func TestCreateTable(t *testing.T) {
 	env := setupTest(t)
 	defer env.cleanup()
@@ -493,3 +508,92 @@ func TestFullAIWorkflow(t *testing.T) {
 
 	t.Log("Full AI workflow verified: CREATE TABLE -> edit schema -> create data -> valid files")
 }
+
+func TestPreparedStatements(t *testing.T) {
+	env := setupTest(t)
+	defer env.cleanup()
+
+	ctx := context.Background()
+	conn := env.connectExtended(t)
+	defer conn.Close(ctx)
+
+	_, err := conn.Exec(ctx, "CREATE TABLE users (id TEXT PRIMARY KEY, name TEXT, age TEXT)")
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	// Parameters are bound as values, quotes and all
+	_, err = conn.Exec(ctx, "INSERT INTO users (id, name, age) VALUES ($1, $2, $3)", "u1", "O'Brien", 42)
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	_, err = conn.Exec(ctx, "INSERT INTO users (id, name, age) VALUES ($1, $2, $3)", "u2", "Bob", nil)
+	if err != nil {
+		t.Fatalf("Failed to insert with NULL: %v", err)
+	}
+
+	var name, age string
+	err = conn.QueryRow(ctx, "SELECT name, age FROM users WHERE id = $1", "u1").Scan(&name, &age)
+	if err != nil {
+		t.Fatalf("Failed to select: %v", err)
+	}
+	if name != "O'Brien" || age != "42" {
+		t.Errorf("Expected O'Brien/42, got %s/%s", name, age)
+	}
+
+	// The cached statement is reused with new parameters
+	err = conn.QueryRow(ctx, "SELECT name, age FROM users WHERE id = $1", "u2").Scan(&name, &age)
+	if err != nil {
+		t.Fatalf("Failed to select u2: %v", err)
+	}
+	if name != "Bob" {
+		t.Errorf("Expected Bob, got %s", name)
+	}
+
+	// A placeholder inside a string literal is left alone
+	rows, err := conn.Query(ctx, "SELECT id FROM users WHERE name = '$1'")
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	count := 0
+	for rows.Next() {
+		count++
+	}
+	rows.Close()
+	if count != 0 {
+		t.Errorf("Expected no rows for literal '$1', got %d", count)
+	}
+
+	// Errors are reported and the connection stays usable
+	if _, err := conn.Exec(ctx, "SELECT id FROM missing WHERE id = $1", "u1"); err == nil {
+		t.Error("Expected error for unknown table")
+	}
+	tag, err := conn.Exec(ctx, "UPDATE users SET name = $1 WHERE id = $2", "Robert", "u2")
+	if err != nil {
+		t.Fatalf("Failed to update after error: %v", err)
+	}
+	if tag.RowsAffected() != 1 {
+		t.Errorf("Expected 1 row updated, got %d", tag.RowsAffected())
+	}
+
+	// LIMIT and OFFSET can be bound too
+	rows, err = conn.Query(ctx, "SELECT id FROM users ORDER BY id LIMIT $1 OFFSET $2", 1, 1)
+	if err != nil {
+		t.Fatalf("Failed to query with LIMIT parameters: %v", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			t.Fatalf("Failed to scan: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		t.Fatalf("LIMIT parameters failed: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != "u2" {
+		t.Errorf("Expected [u2], got %v", ids)
+	}
+}
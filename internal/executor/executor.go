@@ -3,6 +3,10 @@ package executor
 
 import (
 	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/adrianmcphee/smarterbase/internal/storage"
@@ -39,7 +43,7 @@ func (e *Executor) Execute(sql string) (*Result, error) {
 	// Remove trailing semicolon for parser
 	sql = strings.TrimSuffix(sql, ";")
 
-	stmt, err := sqlparser.Parse(sql)
+	stmt, err := sqlparser.Parse(rewriteTableConstraints(sql))
 	if err != nil {
 		return nil, fmt.Errorf("parse error: %w", err)
 	}
@@ -60,6 +64,91 @@ func (e *Executor) Execute(sql string) (*Result, error) {
 	}
 }
 
+// Describe returns the columns a statement would return, without executing
+// it. Statements that return no rows have no columns. Placeholders such as
+// :v1 are allowed, so prepared statements can be described before binding.
+func (e *Executor) Describe(sql string) ([]string, error) {
+	sql = strings.TrimSuffix(strings.TrimSpace(sql), ";")
+	if sql == "" {
+		return nil, nil
+	}
+
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w", err)
+	}
+
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok {
+		return nil, nil
+	}
+
+	if len(sel.From) != 1 {
+		return nil, fmt.Errorf("only single table SELECT supported")
+	}
+	tableName, err := getTableName(sel.From[0])
+	if err != nil {
+		return nil, err
+	}
+	table, err := e.store.Schema.GetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	return selectColumns(sel, table), nil
+}
+
+var (
+	createTablePattern     = regexp.MustCompile(`(?i)^\s*CREATE\s+TABLE\b`)
+	tableConstraintPattern = regexp.MustCompile(`(?i),(\s*)(?:CONSTRAINT\s+\w+\s+)?(UNIQUE|PRIMARY\s+KEY)\s*\(`)
+)
+
+// rewriteTableConstraints turns PostgreSQL table constraints in a CREATE
+// TABLE into the MySQL form the parser accepts. UNIQUE (a, b) becomes
+// UNIQUE KEY with a generated name, and CONSTRAINT names are dropped since
+// they aren't stored. Text inside string literals is left alone.
+func rewriteTableConstraints(sql string) string {
+	if !createTablePattern.MatchString(sql) {
+		return sql
+	}
+
+	var out strings.Builder
+	last, n := 0, 0
+	for _, m := range tableConstraintPattern.FindAllStringSubmatchIndex(sql, -1) {
+		if inStringLiteral(sql, m[0]) {
+			continue
+		}
+		out.WriteString(sql[last:m[0]])
+		out.WriteString("," + sql[m[2]:m[3]])
+		if strings.EqualFold(sql[m[4]:m[5]], "UNIQUE") {
+			n++
+			fmt.Fprintf(&out, "UNIQUE KEY unique_%d (", n)
+		} else {
+			out.WriteString("PRIMARY KEY (")
+		}
+		last = m[1]
+	}
+	out.WriteString(sql[last:])
+	return out.String()
+}
+
+// inStringLiteral reports whether position pos in sql falls inside a quoted
+// string, allowing for backslash escapes and doubled quotes
+func inStringLiteral(sql string, pos int) bool {
+	var quote byte
+	for i := 0; i < pos; i++ {
+		switch c := sql[i]; {
+		case quote == 0 && (c == '\'' || c == '"'):
+			quote = c
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		}
+	}
+	return quote != 0
+}
+
 // executeDDL handles CREATE TABLE, DROP TABLE, etc.
 func (e *Executor) executeDDL(stmt *sqlparser.DDL) (*Result, error) {
 	switch stmt.Action {
@@ -98,11 +187,42 @@ func (e *Executor) executeCreateTable(stmt *sqlparser.DDL) (*Result, error) {
 			column.PrimaryKey = true
 		}
 
+		// Check for UNIQUE in column options
+		if col.Type.KeyOpt == 3 || col.Type.KeyOpt == 4 { // colKeyUnique, colKeyUniqueKey
+			column.Unique = true
+		}
+
+		// Record DEFAULT as a PostgreSQL literal; strings are requoted with ''
+		if def := col.Type.Default; def != nil {
+			if def.Type == sqlparser.StrVal {
+				column.Default = "'" + strings.ReplaceAll(string(def.Val), "'", "''") + "'"
+			} else {
+				column.Default = string(def.Val)
+			}
+		}
+
 		columns = append(columns, column)
 	}
 
 	// Check table-level constraints (like PRIMARY KEY)
+	var unique [][]string
 	for _, idx := range stmt.TableSpec.Indexes {
+		if idx.Info.Unique && !idx.Info.Primary {
+			if len(idx.Columns) == 1 {
+				// Single-column UNIQUE KEY is the same as a column constraint
+				for i, col := range columns {
+					if col.Name == idx.Columns[0].Column.String() {
+						columns[i].Unique = true
+					}
+				}
+			} else {
+				cols := make([]string, len(idx.Columns))
+				for i, idxCol := range idx.Columns {
+					cols[i] = idxCol.Column.String()
+				}
+				unique = append(unique, cols)
+			}
+		}
 		if idx.Info.Primary {
 			// Mark the column as primary key
 			for i, col := range columns {
@@ -118,6 +238,7 @@ func (e *Executor) executeCreateTable(stmt *sqlparser.DDL) (*Result, error) {
 	table := &storage.Table{
 		Name:    tableName,
 		Columns: columns,
+		Unique:  unique,
 	}
 
 	if err := e.store.Schema.CreateTable(table); err != nil {
@@ -150,64 +271,220 @@ func (e *Executor) executeSelect(stmt *sqlparser.Select) (*Result, error) {
 		return nil, err
 	}
 
+	// Reject clauses we can't honour rather than silently ignoring them
+	if stmt.Having != nil {
+		return nil, fmt.Errorf("HAVING is not supported")
+	}
+
 	// Get table schema for column info
 	table, err := e.store.Schema.GetTable(tableName)
 	if err != nil {
 		return nil, err
 	}
 
+	if len(stmt.GroupBy) > 0 || hasCount(stmt.SelectExprs) {
+		return e.executeAggregate(stmt, table)
+	}
+
 	// Scan all rows
 	rows, err := e.store.Data.Scan(tableName)
 	if err != nil {
 		return nil, err
 	}
 
-	// Determine which columns to return
-	var columns []string
-	selectAll := false
+	columns := selectColumns(stmt, table)
+
+	// Apply WHERE clause filter
+	filteredRows, err := filterRows(table, rows, stmt.Where)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort before projecting, so ORDER BY can use columns not selected
+	if err := sortRows(table, filteredRows, stmt.OrderBy); err != nil {
+		return nil, err
+	}
+
+	// Convert to string matrix for result
+	resultRows := make([][]string, len(filteredRows))
+	for i, row := range filteredRows {
+		resultRows[i] = make([]string, len(columns))
+		for j, col := range columns {
+			if val, ok := row[col]; ok && val != nil {
+				resultRows[i][j] = fmt.Sprintf("%v", val)
+			} else {
+				resultRows[i][j] = ""
+			}
+		}
+	}
 
+	// Apply DISTINCT, keeping the first occurrence of each row
+	if stmt.Distinct != "" {
+		resultRows = distinctRows(resultRows)
+	}
+
+	resultRows, err = applyLimit(resultRows, stmt.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Columns: columns,
+		Rows:    resultRows,
+		Message: fmt.Sprintf("SELECT %d", len(resultRows)),
+	}, nil
+}
+
+// aggregateOutput is one result column of an aggregate SELECT: either a
+// GROUP BY column or a COUNT
+type aggregateOutput struct {
+	group    int    // index into the GROUP BY columns, -1 for a count
+	countCol string // column counted by COUNT(col); empty for COUNT(*)
+}
+
+// aggregateGroup accumulates counts for one GROUP BY key
+type aggregateGroup struct {
+	values []any
+	counts []int
+}
+
+// executeAggregate handles SELECT with COUNT or GROUP BY. Rows are counted
+// in a single streaming pass rather than loading the table.
+func (e *Executor) executeAggregate(stmt *sqlparser.Select, table *storage.Table) (*Result, error) {
+	var groupCols []string
+	for _, expr := range stmt.GroupBy {
+		col, ok := expr.(*sqlparser.ColName)
+		if !ok {
+			return nil, fmt.Errorf("unsupported GROUP BY expression: %s", sqlparser.String(expr))
+		}
+		groupCols = append(groupCols, col.Name.String())
+	}
+
+	var outputs []aggregateOutput
 	for _, expr := range stmt.SelectExprs {
-		switch e := expr.(type) {
-		case *sqlparser.StarExpr:
-			selectAll = true
-		case *sqlparser.AliasedExpr:
-			if col, ok := e.Expr.(*sqlparser.ColName); ok {
-				columns = append(columns, col.Name.String())
+		aliased, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok {
+			return nil, fmt.Errorf("unsupported select expression with GROUP BY or COUNT: %s", sqlparser.String(expr))
+		}
+
+		switch expr := aliased.Expr.(type) {
+		case *sqlparser.ColName:
+			group := -1
+			for i, name := range groupCols {
+				if name == expr.Name.String() {
+					group = i
+				}
+			}
+			if group < 0 {
+				return nil, fmt.Errorf("column %s must appear in the GROUP BY clause or be used in an aggregate function", expr.Name.String())
 			}
+			outputs = append(outputs, aggregateOutput{group: group})
+		case *sqlparser.FuncExpr:
+			countCol, err := countArgument(expr)
+			if err != nil {
+				return nil, err
+			}
+			outputs = append(outputs, aggregateOutput{group: -1, countCol: countCol})
+		default:
+			return nil, fmt.Errorf("unsupported select expression with GROUP BY or COUNT: %s", sqlparser.String(expr))
 		}
 	}
 
-	if selectAll {
-		columns = make([]string, len(table.Columns))
-		for i, col := range table.Columns {
-			columns[i] = col.Name
+	// ORDER BY may name a GROUP BY column or COUNT that isn't selected; it's
+	// computed as a hidden output and trimmed after sorting
+	columns := selectColumns(stmt, nil)
+	sortBy, outputs, err := aggregateOrderBy(stmt.OrderBy, columns, groupCols, outputs)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]*aggregateGroup)
+	var order []string
+
+	err = e.store.Data.ScanFunc(table.Name, func(row storage.Row) error {
+		if stmt.Where != nil {
+			match, err := matchesWhere(table, row, stmt.Where.Expr)
+			if err != nil || match != truthTrue {
+				return err
+			}
+		}
+
+		values := make([]any, len(groupCols))
+		for i, col := range groupCols {
+			values[i] = row[col]
+		}
+		key := groupKey(values)
+
+		group, ok := groups[key]
+		if !ok {
+			group = &aggregateGroup{values: values, counts: make([]int, len(outputs))}
+			groups[key] = group
+			order = append(order, key)
 		}
+
+		for i, out := range outputs {
+			if out.group < 0 && (out.countCol == "" || row[out.countCol] != nil) {
+				group.counts[i]++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Apply WHERE clause filter
-	filteredRows := rows
-	if stmt.Where != nil {
-		filteredRows = make([]storage.Row, 0)
-		for _, row := range rows {
-			if matchesWhere(row, stmt.Where.Expr) {
-				filteredRows = append(filteredRows, row)
+	// Without GROUP BY there's always exactly one row, even for no matches
+	if len(groupCols) == 0 && len(order) == 0 {
+		groups[""] = &aggregateGroup{counts: make([]int, len(outputs))}
+		order = append(order, "")
+	}
+
+	// Keep values typed until sorted, so a NULL group sorts as NULL
+	// rather than as an empty string
+	values := make([][]any, len(order))
+	for i, key := range order {
+		group := groups[key]
+		values[i] = make([]any, len(outputs))
+		for j, out := range outputs {
+			if out.group < 0 {
+				values[i][j] = group.counts[j]
+			} else {
+				values[i][j] = group.values[out.group]
 			}
 		}
 	}
 
-	// Convert to string matrix for result
-	resultRows := make([][]string, len(filteredRows))
-	for i, row := range filteredRows {
+	// Counts are numbers; GROUP BY values compare as their column's type
+	numeric := make([]bool, len(outputs))
+	for i, out := range outputs {
+		numeric[i] = out.group < 0
+		if !numeric[i] {
+			col, ok := table.Column(groupCols[out.group])
+			numeric[i] = ok && col.IsNumeric()
+		}
+	}
+
+	sortResultRows(values, sortBy, numeric, stmt.OrderBy)
+
+	resultRows := make([][]string, len(values))
+	for i, row := range values {
 		resultRows[i] = make([]string, len(columns))
-		for j, col := range columns {
-			if val, ok := row[col]; ok {
+		for j, val := range row[:len(columns)] {
+			if val != nil {
 				resultRows[i][j] = fmt.Sprintf("%v", val)
-			} else {
-				resultRows[i][j] = ""
 			}
 		}
 	}
 
+	if stmt.Distinct != "" {
+		resultRows = distinctRows(resultRows)
+	}
+
+	resultRows, err = applyLimit(resultRows, stmt.Limit)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Result{
 		Columns: columns,
 		Rows:    resultRows,
@@ -231,22 +508,32 @@ func (e *Executor) executeInsert(stmt *sqlparser.Insert) (*Result, error) {
 		return nil, fmt.Errorf("only VALUES clause supported for INSERT")
 	}
 
-	var lastID string
-	for _, valTuple := range rows {
+	newRows := make([]storage.Row, len(rows))
+	for i, valTuple := range rows {
 		row := make(storage.Row)
 
-		for i, val := range valTuple {
-			if i < len(columns) {
-				colName := columns[i]
+		for j, val := range valTuple {
+			// DEFAULT leaves the column out, so the column default applies
+			if _, ok := val.(*sqlparser.Default); ok {
+				continue
+			}
+			if j < len(columns) {
+				colName := columns[j]
 				row[colName] = evalExpr(val)
 			}
 		}
+		newRows[i] = row
+	}
 
-		id, err := e.store.Data.Insert(tableName, row)
-		if err != nil {
-			return nil, err
-		}
-		lastID = id
+	// Insert all rows in one write, so a failing row leaves none behind
+	ids, err := e.store.Data.InsertBatch(tableName, newRows)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastID string
+	if len(ids) > 0 {
+		lastID = ids[len(ids)-1]
 	}
 
 	return &Result{
@@ -267,11 +554,20 @@ func (e *Executor) executeUpdate(stmt *sqlparser.Update) (*Result, error) {
 		return nil, err
 	}
 
+	table, err := e.store.Schema.GetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get all rows and filter by WHERE
 	rows, err := e.store.Data.Scan(tableName)
 	if err != nil {
 		return nil, err
 	}
+	rows, err = filterRows(table, rows, stmt.Where)
+	if err != nil {
+		return nil, err
+	}
 
 	// Build updates map
 	updates := make(storage.Row)
@@ -280,21 +576,20 @@ func (e *Executor) executeUpdate(stmt *sqlparser.Update) (*Result, error) {
 		updates[colName] = evalExpr(expr.Expr)
 	}
 
-	// Apply updates to matching rows
-	affected := 0
+	// Apply updates to matching rows in one write, so a constraint
+	// violation on any row leaves every row unchanged
+	var ids []string
 	for _, row := range rows {
-		if stmt.Where == nil || matchesWhere(row, stmt.Where.Expr) {
-			id, ok := row["id"].(string)
-			if !ok {
-				continue
-			}
-			if err := e.store.Data.Update(tableName, id, updates); err != nil {
-				return nil, err
-			}
-			affected++
+		if id, ok := row["id"].(string); ok {
+			ids = append(ids, id)
 		}
 	}
 
+	affected, err := e.store.Data.UpdateBatch(tableName, ids, updates)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Result{
 		RowsAffected: affected,
 		Message:      fmt.Sprintf("UPDATE %d", affected),
@@ -312,25 +607,34 @@ func (e *Executor) executeDelete(stmt *sqlparser.Delete) (*Result, error) {
 		return nil, err
 	}
 
+	table, err := e.store.Schema.GetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get all rows and filter by WHERE
 	rows, err := e.store.Data.Scan(tableName)
 	if err != nil {
 		return nil, err
 	}
+	rows, err = filterRows(table, rows, stmt.Where)
+	if err != nil {
+		return nil, err
+	}
 
-	// Delete matching rows
-	affected := 0
+	// Collect matching IDs and delete them in one rewrite
+	var ids []string
 	for _, row := range rows {
-		if stmt.Where == nil || matchesWhere(row, stmt.Where.Expr) {
-			id, ok := row["id"].(string)
-			if !ok {
-				continue
-			}
-			if err := e.store.Data.Delete(tableName, id); err != nil {
-				return nil, err
-			}
-			affected++
+		id, ok := row["id"].(string)
+		if !ok {
+			continue
 		}
+		ids = append(ids, id)
+	}
+
+	affected, err := e.store.Data.DeleteBatch(tableName, ids)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Result{
@@ -341,6 +645,39 @@ func (e *Executor) executeDelete(stmt *sqlparser.Delete) (*Result, error) {
 
 // Helper functions
 
+// selectColumns returns the result column names of a SELECT
+func selectColumns(stmt *sqlparser.Select, table *storage.Table) []string {
+	var columns []string
+	selectAll := false
+
+	for _, expr := range stmt.SelectExprs {
+		switch e := expr.(type) {
+		case *sqlparser.StarExpr:
+			selectAll = true
+		case *sqlparser.AliasedExpr:
+			switch {
+			case !e.As.IsEmpty():
+				columns = append(columns, e.As.String())
+			case isCount(e.Expr):
+				columns = append(columns, "count") // PostgreSQL's default name
+			default:
+				if col, ok := e.Expr.(*sqlparser.ColName); ok {
+					columns = append(columns, col.Name.String())
+				}
+			}
+		}
+	}
+
+	if selectAll {
+		columns = make([]string, len(table.Columns))
+		for i, col := range table.Columns {
+			columns[i] = col.Name
+		}
+	}
+
+	return columns
+}
+
 func getTableName(expr sqlparser.TableExpr) (string, error) {
 	switch t := expr.(type) {
 	case *sqlparser.AliasedTableExpr:
@@ -351,6 +688,23 @@ func getTableName(expr sqlparser.TableExpr) (string, error) {
 	return "", fmt.Errorf("could not determine table name")
 }
 
+func distinctRows(rows [][]string) [][]string {
+	seen := make(map[string]bool, len(rows))
+	unique := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		var key strings.Builder
+		for _, val := range row {
+			writeKeyPart(&key, val)
+		}
+		if seen[key.String()] {
+			continue
+		}
+		seen[key.String()] = true
+		unique = append(unique, row)
+	}
+	return unique
+}
+
 func evalExpr(expr sqlparser.Expr) any {
 	switch e := expr.(type) {
 	case *sqlparser.SQLVal:
@@ -362,8 +716,18 @@ func evalExpr(expr sqlparser.Expr) any {
 		case sqlparser.FloatVal:
 			return string(e.Val)
 		}
+	case sqlparser.BoolVal:
+		// Stored as text, matching a DEFAULT true or false
+		return strconv.FormatBool(bool(e))
 	case *sqlparser.NullVal:
 		return nil
+	case *sqlparser.UnaryExpr:
+		// Negative numeric literals
+		if e.Operator == sqlparser.UMinusStr {
+			if val, ok := evalExpr(e.Expr).(string); ok {
+				return "-" + val
+			}
+		}
 	case *sqlparser.FuncExpr:
 		// Handle gen_random_uuid7()
 		if strings.ToLower(e.Name.String()) == "gen_random_uuid7" {
@@ -373,30 +737,606 @@ func evalExpr(expr sqlparser.Expr) any {
 	return nil
 }
 
-func matchesWhere(row storage.Row, expr sqlparser.Expr) bool {
+// truth is the value of a WHERE condition under SQL's three-valued logic.
+// Comparisons involving NULL are unknown, and a row only matches when the
+// whole condition is true.
+type truth int
+
+const (
+	truthFalse truth = iota
+	truthTrue
+	truthUnknown
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return truthTrue
+	}
+	return truthFalse
+}
+
+// not negates t; NOT unknown is unknown
+func (t truth) not() truth {
+	switch t {
+	case truthTrue:
+		return truthFalse
+	case truthFalse:
+		return truthTrue
+	}
+	return truthUnknown
+}
+
+// and is false if either side is false, otherwise unknown if either is
+func (t truth) and(other truth) truth {
+	switch {
+	case t == truthFalse || other == truthFalse:
+		return truthFalse
+	case t == truthUnknown || other == truthUnknown:
+		return truthUnknown
+	}
+	return truthTrue
+}
+
+// or is true if either side is true, otherwise unknown if either is
+func (t truth) or(other truth) truth {
+	switch {
+	case t == truthTrue || other == truthTrue:
+		return truthTrue
+	case t == truthUnknown || other == truthUnknown:
+		return truthUnknown
+	}
+	return truthFalse
+}
+
+// matchesWhere evaluates a WHERE expression against a row of table.
+// Expressions it can't evaluate are an error rather than matching every row.
+func matchesWhere(table *storage.Table, row storage.Row, expr sqlparser.Expr) (truth, error) {
 	switch e := expr.(type) {
 	case *sqlparser.ComparisonExpr:
-		left := getColumnValue(row, e.Left)
-		right := evalExpr(e.Right)
+		left, err := operandValue(row, e.Left)
+		if err != nil {
+			return truthFalse, err
+		}
+
+		switch e.Operator {
+		case sqlparser.InStr, sqlparser.NotInStr:
+			tuple, ok := e.Right.(sqlparser.ValTuple)
+			if !ok {
+				return truthFalse, fmt.Errorf("unsupported IN list: %s", sqlparser.String(e.Right))
+			}
+			// x IN (a, b) is x = a OR x = b, so a NULL member makes a
+			// miss unknown rather than false
+			numeric := isNumeric(table, append(sqlparser.Exprs{e.Left}, tuple...)...)
+			in := truthFalse
+			for _, expr := range tuple {
+				val, err := operandValue(row, expr)
+				if err != nil {
+					return truthFalse, err
+				}
+				if left == nil || val == nil {
+					in = in.or(truthUnknown)
+				} else {
+					in = in.or(truthOf(compareValues(left, val, numeric) == 0))
+				}
+			}
+			if e.Operator == sqlparser.NotInStr {
+				return in.not(), nil
+			}
+			return in, nil
+		case sqlparser.LikeStr, sqlparser.NotLikeStr:
+			right, err := operandValue(row, e.Right)
+			if err != nil {
+				return truthFalse, err
+			}
+			escape, err := likeEscape(row, e.Escape)
+			if err != nil {
+				return truthFalse, err
+			}
+			pattern, ok := right.(string)
+			if !ok || left == nil {
+				return truthUnknown, nil
+			}
+			return truthOf(matchLike(fmt.Sprintf("%v", left), pattern, escape) == (e.Operator == sqlparser.LikeStr)), nil
+		}
+
+		right, err := operandValue(row, e.Right)
+		if err != nil {
+			return truthFalse, err
+		}
+		if left == nil || right == nil {
+			return truthUnknown, nil
+		}
+
+		numeric := isNumeric(table, e.Left, e.Right)
 
 		switch e.Operator {
-		case "=":
-			return fmt.Sprintf("%v", left) == fmt.Sprintf("%v", right)
-		case "!=", "<>":
-			return fmt.Sprintf("%v", left) != fmt.Sprintf("%v", right)
+		case sqlparser.EqualStr:
+			return truthOf(compareValues(left, right, numeric) == 0), nil
+		case sqlparser.NotEqualStr, "<>":
+			return truthOf(compareValues(left, right, numeric) != 0), nil
+		case sqlparser.LessThanStr:
+			return truthOf(compareValues(left, right, numeric) < 0), nil
+		case sqlparser.LessEqualStr:
+			return truthOf(compareValues(left, right, numeric) <= 0), nil
+		case sqlparser.GreaterThanStr:
+			return truthOf(compareValues(left, right, numeric) > 0), nil
+		case sqlparser.GreaterEqualStr:
+			return truthOf(compareValues(left, right, numeric) >= 0), nil
+		}
+		return truthFalse, fmt.Errorf("unsupported operator in WHERE: %s", e.Operator)
+	case *sqlparser.RangeCond:
+		left, err := operandValue(row, e.Left)
+		if err != nil {
+			return truthFalse, err
 		}
+		from, err := operandValue(row, e.From)
+		if err != nil {
+			return truthFalse, err
+		}
+		to, err := operandValue(row, e.To)
+		if err != nil {
+			return truthFalse, err
+		}
+
+		// x BETWEEN a AND b is x >= a AND x <= b, so one NULL bound can
+		// still make it false
+		numeric := isNumeric(table, e.Left, e.From, e.To)
+		lower, upper := truthUnknown, truthUnknown
+		if left != nil && from != nil {
+			lower = truthOf(compareValues(left, from, numeric) >= 0)
+		}
+		if left != nil && to != nil {
+			upper = truthOf(compareValues(left, to, numeric) <= 0)
+		}
+		between := lower.and(upper)
+		if e.Operator == sqlparser.NotBetweenStr {
+			return between.not(), nil
+		}
+		return between, nil
+	case *sqlparser.IsExpr:
+		val, err := operandValue(row, e.Expr)
+		if err != nil {
+			return truthFalse, err
+		}
+		switch e.Operator {
+		case sqlparser.IsNullStr:
+			return truthOf(val == nil), nil
+		case sqlparser.IsNotNullStr:
+			return truthOf(val != nil), nil
+		}
+		return truthFalse, fmt.Errorf("unsupported operator in WHERE: %s", e.Operator)
 	case *sqlparser.AndExpr:
-		return matchesWhere(row, e.Left) && matchesWhere(row, e.Right)
+		// Both sides are evaluated so unsupported expressions always error
+		left, err := matchesWhere(table, row, e.Left)
+		if err != nil {
+			return truthFalse, err
+		}
+		right, err := matchesWhere(table, row, e.Right)
+		if err != nil {
+			return truthFalse, err
+		}
+		return left.and(right), nil
 	case *sqlparser.OrExpr:
-		return matchesWhere(row, e.Left) || matchesWhere(row, e.Right)
+		left, err := matchesWhere(table, row, e.Left)
+		if err != nil {
+			return truthFalse, err
+		}
+		right, err := matchesWhere(table, row, e.Right)
+		if err != nil {
+			return truthFalse, err
+		}
+		return left.or(right), nil
+	case *sqlparser.NotExpr:
+		match, err := matchesWhere(table, row, e.Expr)
+		if err != nil {
+			return truthFalse, err
+		}
+		return match.not(), nil
+	case *sqlparser.ParenExpr:
+		return matchesWhere(table, row, e.Expr)
 	}
-	return true
+	return truthFalse, fmt.Errorf("unsupported WHERE expression: %s", sqlparser.String(expr))
+}
+
+// isCount reports whether expr is a COUNT(...) call
+func isCount(expr sqlparser.Expr) bool {
+	fn, ok := expr.(*sqlparser.FuncExpr)
+	return ok && fn.Name.Lowered() == "count"
 }
 
-func getColumnValue(row storage.Row, expr sqlparser.Expr) any {
+// hasCount reports whether a select list contains COUNT
+func hasCount(exprs sqlparser.SelectExprs) bool {
+	for _, expr := range exprs {
+		if aliased, ok := expr.(*sqlparser.AliasedExpr); ok && isCount(aliased.Expr) {
+			return true
+		}
+	}
+	return false
+}
+
+// countArgument returns the column counted by COUNT(col), or "" for COUNT(*)
+func countArgument(fn *sqlparser.FuncExpr) (string, error) {
+	if !isCount(fn) {
+		return "", fmt.Errorf("unsupported function: %s", sqlparser.String(fn))
+	}
+	if fn.Distinct || len(fn.Exprs) != 1 {
+		return "", fmt.Errorf("unsupported aggregate: %s", sqlparser.String(fn))
+	}
+
+	switch arg := fn.Exprs[0].(type) {
+	case *sqlparser.StarExpr:
+		return "", nil
+	case *sqlparser.AliasedExpr:
+		if col, ok := arg.Expr.(*sqlparser.ColName); ok {
+			return col.Name.String(), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported aggregate: %s", sqlparser.String(fn))
+}
+
+// groupKey encodes GROUP BY values as a map key, keeping NULL distinct from
+// an empty string
+func groupKey(values []any) string {
+	var key strings.Builder
+	for _, val := range values {
+		if val == nil {
+			key.WriteString("-")
+		} else {
+			writeKeyPart(&key, fmt.Sprintf("%v", val))
+		}
+	}
+	return key.String()
+}
+
+// writeKeyPart appends a length-prefixed value to a composite key. Values
+// may contain any byte, including NUL, so a plain separator could let two
+// different tuples produce the same key.
+func writeKeyPart(key *strings.Builder, val string) {
+	fmt.Fprintf(key, "%d:%s", len(val), val)
+}
+
+// aggregateOrderBy resolves each ORDER BY term to an aggregate output,
+// trying a result column name or alias, then a GROUP BY column, then a COUNT
+// call. Terms that match no selected output are appended as hidden outputs.
+// It returns the output index for each term and the extended outputs.
+func aggregateOrderBy(orderBy sqlparser.OrderBy, columns, groupCols []string, outputs []aggregateOutput) ([]int, []aggregateOutput, error) {
+	indexes := make([]int, len(orderBy))
+	for i, order := range orderBy {
+		indexes[i] = -1
+
+		var want aggregateOutput
+		switch expr := order.Expr.(type) {
+		case *sqlparser.ColName:
+			name := expr.Name.String()
+			for j, col := range columns {
+				if col == name {
+					indexes[i] = j
+				}
+			}
+			if indexes[i] >= 0 {
+				continue
+			}
+
+			want.group = -1
+			for j, col := range groupCols {
+				if col == name {
+					want.group = j
+				}
+			}
+			if want.group < 0 {
+				return nil, nil, fmt.Errorf("column %s must appear in the GROUP BY clause or be used in an aggregate function", name)
+			}
+		case *sqlparser.FuncExpr:
+			countCol, err := countArgument(expr)
+			if err != nil {
+				return nil, nil, err
+			}
+			want = aggregateOutput{group: -1, countCol: countCol}
+		default:
+			return nil, nil, fmt.Errorf("unsupported ORDER BY expression: %s", sqlparser.String(order.Expr))
+		}
+
+		for j, out := range outputs {
+			if out == want {
+				indexes[i] = j
+				break
+			}
+		}
+		if indexes[i] < 0 {
+			outputs = append(outputs, want)
+			indexes[i] = len(outputs) - 1
+		}
+	}
+	return indexes, outputs, nil
+}
+
+// sortResultRows orders aggregate result rows by the output indexes that
+// aggregateOrderBy resolved. numeric flags the outputs that compare as
+// numbers. NULLs are ordered as in sortRows.
+func sortResultRows(rows [][]any, indexes []int, numeric []bool, orderBy sqlparser.OrderBy) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		for k, idx := range indexes {
+			cmp := compareNullable(rows[i][idx], rows[j][idx], numeric[idx])
+			if orderBy[k].Direction == sqlparser.DescScr {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+}
+
+// sortRows orders rows of table in place by ORDER BY columns. As in
+// PostgreSQL, NULLs sort after other values, so they come last ascending and
+// first descending.
+func sortRows(table *storage.Table, rows []storage.Row, orderBy sqlparser.OrderBy) error {
+	if len(orderBy) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(orderBy))
+	numeric := make([]bool, len(orderBy))
+	for i, order := range orderBy {
+		col, ok := order.Expr.(*sqlparser.ColName)
+		if !ok {
+			return fmt.Errorf("unsupported ORDER BY expression: %s", sqlparser.String(order.Expr))
+		}
+		keys[i] = col.Name.String()
+		numeric[i] = isNumeric(table, col)
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		for k, key := range keys {
+			cmp := compareNullable(rows[i][key], rows[j][key], numeric[k])
+			if orderBy[k].Direction == sqlparser.DescScr {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+
+	return nil
+}
+
+// applyLimit applies LIMIT and OFFSET to result rows
+func applyLimit(rows [][]string, limit *sqlparser.Limit) ([][]string, error) {
+	if limit == nil {
+		return rows, nil
+	}
+
+	if limit.Offset != nil {
+		offset, err := limitValue(limit.Offset, "OFFSET")
+		if err != nil {
+			return nil, err
+		}
+		if offset > len(rows) {
+			offset = len(rows)
+		}
+		rows = rows[offset:]
+	}
+
+	if limit.Rowcount != nil {
+		count, err := limitValue(limit.Rowcount, "LIMIT")
+		if err != nil {
+			return nil, err
+		}
+		if count < len(rows) {
+			rows = rows[:count]
+		}
+	}
+
+	return rows, nil
+}
+
+// limitValue reads a LIMIT or OFFSET count. A quoted count such as '10' is
+// accepted too, since bound parameters are substituted as string literals.
+func limitValue(expr sqlparser.Expr, clause string) (int, error) {
+	if val, ok := expr.(*sqlparser.SQLVal); ok && (val.Type == sqlparser.IntVal || val.Type == sqlparser.StrVal) {
+		if n, err := strconv.Atoi(string(val.Val)); err == nil && n >= 0 {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("%s must be a non-negative integer, got %s", clause, sqlparser.String(expr))
+}
+
+// filterRows returns the rows of table matching a WHERE clause, or all rows
+// if nil
+func filterRows(table *storage.Table, rows []storage.Row, where *sqlparser.Where) ([]storage.Row, error) {
+	if where == nil {
+		return rows, nil
+	}
+
+	matched := make([]storage.Row, 0)
+	for _, row := range rows {
+		match, err := matchesWhere(table, row, where.Expr)
+		if err != nil {
+			return nil, err
+		}
+		if match == truthTrue {
+			matched = append(matched, row)
+		}
+	}
+	return matched, nil
+}
+
+// likeEscape returns the escape character for a LIKE: backslash by default,
+// or the single character given with ESCAPE. An empty ESCAPE string disables
+// escaping and returns 0.
+func likeEscape(row storage.Row, expr sqlparser.Expr) (rune, error) {
+	if expr == nil {
+		return '\\', nil
+	}
+
+	val, err := operandValue(row, expr)
+	if err != nil {
+		return 0, err
+	}
+	s, ok := val.(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid LIKE escape: %s", sqlparser.String(expr))
+	}
+
+	switch r := []rune(s); len(r) {
+	case 0:
+		return 0, nil
+	case 1:
+		return r[0], nil
+	}
+	return 0, fmt.Errorf("LIKE escape must be a single character: %s", sqlparser.String(expr))
+}
+
+// matchLike reports whether s matches a SQL LIKE pattern, where % matches any
+// run of characters, _ matches exactly one, and escape (if not 0) makes the
+// next character literal. Matching is case-sensitive, as in PostgreSQL.
+//
+// The SQL tokenizer already treats backslash as an escape inside string
+// literals, so '100\\%' is needed to reach here as 100\% and match a literal
+// percent sign; '100\%' with a single backslash arrives as 100%.
+// ESCAPE avoids the doubling: LIKE '100!%' ESCAPE '!'.
+func matchLike(s, pattern string, escape rune) bool {
+	str, pat := []rune(s), []rune(pattern)
+	si, pi := 0, 0
+	starPi, starSi := -1, 0
+
+	for si < len(str) {
+		if pi < len(pat) {
+			switch {
+			case pat[pi] == '%':
+				// Remember the wildcard and try matching zero characters first
+				starPi, starSi = pi, si
+				pi++
+				continue
+			case pat[pi] == '_':
+				si++
+				pi++
+				continue
+			case escape != 0 && pat[pi] == escape && pi+1 < len(pat):
+				if pat[pi+1] == str[si] {
+					si++
+					pi += 2
+					continue
+				}
+			case pat[pi] == str[si]:
+				si++
+				pi++
+				continue
+			}
+		}
+
+		// Mismatch: let the last % absorb one more character
+		if starPi < 0 {
+			return false
+		}
+		starSi++
+		si = starSi
+		pi = starPi + 1
+	}
+
+	// Only trailing % can match the empty remainder
+	for pi < len(pat) && pat[pi] == '%' {
+		pi++
+	}
+	return pi == len(pat)
+}
+
+// compareNullable orders two values like compareValues, with NULL after
+// every other value
+func compareNullable(a, b any, numeric bool) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return compareValues(a, b, numeric)
+}
+
+// compareValues orders two non-NULL values. With numeric set (a numeric
+// column is involved) values compare as numbers, and any that aren't numbers
+// sort after those that are; JSON numbers decode as float64 and SQL literals
+// arrive as strings. Otherwise values compare as text, so '007' and '7' in a
+// TEXT column are different values.
+func compareValues(a, b any, numeric bool) int {
+	if numeric {
+		af, aNum := toFloat(a)
+		bf, bNum := toFloat(b)
+		switch {
+		case aNum && bNum:
+			switch {
+			case af < bf:
+				return -1
+			case af > bf:
+				return 1
+			default:
+				return 0
+			}
+		case aNum:
+			return -1
+		case bNum:
+			return 1
+		}
+	}
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+// toFloat converts a stored or literal value to a finite number, if it is
+// one. NaN and infinities are not numbers here: NaN would compare equal to
+// everything.
+func toFloat(v any) (float64, bool) {
+	var f float64
+	switch n := v.(type) {
+	case float64:
+		f = n
+	case int:
+		f = float64(n)
+	case int64:
+		f = float64(n)
+	case string:
+		var err error
+		if f, err = strconv.ParseFloat(n, 64); err != nil {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
+
+// isNumeric reports whether any of exprs is a column with a numeric type,
+// making a comparison involving it numeric
+func isNumeric(table *storage.Table, exprs ...sqlparser.Expr) bool {
+	for _, expr := range exprs {
+		if name, ok := expr.(*sqlparser.ColName); ok {
+			if col, ok := table.Column(name.Name.String()); ok && col.IsNumeric() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// operandValue evaluates one side of a comparison: a column or a literal
+func operandValue(row storage.Row, expr sqlparser.Expr) (any, error) {
 	switch e := expr.(type) {
 	case *sqlparser.ColName:
-		return row[e.Name.String()]
+		return row[e.Name.String()], nil
+	case *sqlparser.NullVal, *sqlparser.UnaryExpr, sqlparser.BoolVal:
+		return evalExpr(e), nil
+	case *sqlparser.SQLVal:
+		if e.Type == sqlparser.ValArg {
+			return nil, fmt.Errorf("unbound parameter %s", e.Val)
+		}
+		return evalExpr(e), nil
 	}
-	return nil
+	return nil, fmt.Errorf("unsupported value in WHERE: %s", sqlparser.String(expr))
 }
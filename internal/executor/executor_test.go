@@ -0,0 +1,815 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/adrianmcphee/smarterbase/internal/export"
+	"github.com/adrianmcphee/smarterbase/internal/storage"
+)
+
+func setupTestExecutor(t *testing.T) *Executor {
+	t.Helper()
+
+	store, err := storage.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	return NewExecutor(store)
+}
+
+// mustExec runs each statement and fails the test on the first error
+func mustExec(t *testing.T, e *Executor, stmts ...string) *Result {
+	t.Helper()
+
+	var result *Result
+	for _, sql := range stmts {
+		var err error
+		result, err = e.Execute(sql)
+		if err != nil {
+			t.Fatalf("Execute(%q) failed: %v", sql, err)
+		}
+	}
+	return result
+}
+
+func TestDelete_MultipleRows(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e,
+		"CREATE TABLE tasks (id TEXT PRIMARY KEY, state TEXT)",
+		"INSERT INTO tasks (id, state) VALUES ('t1', 'done'), ('t2', 'open'), ('t3', 'done')",
+	)
+
+	result := mustExec(t, e, "DELETE FROM tasks WHERE state = 'done'")
+	if result.RowsAffected != 2 {
+		t.Errorf("Expected 2 rows deleted, got %d", result.RowsAffected)
+	}
+
+	result = mustExec(t, e, "SELECT id FROM tasks")
+	if len(result.Rows) != 1 || result.Rows[0][0] != "t2" {
+		t.Errorf("Expected only t2 to remain, got %v", result.Rows)
+	}
+}
+
+func TestCreateTable_Unique(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e, "CREATE TABLE users (id TEXT PRIMARY KEY, email TEXT UNIQUE, name TEXT)")
+
+	table, err := e.store.Schema.GetTable("users")
+	if err != nil {
+		t.Fatalf("Failed to get table: %v", err)
+	}
+	if !table.Columns[1].Unique {
+		t.Error("Expected email column to be unique")
+	}
+	if table.Columns[2].Unique {
+		t.Error("Expected name column not to be unique")
+	}
+}
+
+func TestInsert_UniqueViolation(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e,
+		"CREATE TABLE users (id TEXT PRIMARY KEY, email TEXT UNIQUE)",
+		"INSERT INTO users (id, email) VALUES ('u1', 'alice@example.com')",
+	)
+
+	_, err := e.Execute("INSERT INTO users (id, email) VALUES ('u2', 'alice@example.com')")
+	if err == nil || !strings.Contains(err.Error(), "unique column email") {
+		t.Fatalf("Expected unique violation, got %v", err)
+	}
+
+	// NULLs never conflict
+	mustExec(t, e,
+		"INSERT INTO users (id, email) VALUES ('u3', NULL)",
+		"INSERT INTO users (id, email) VALUES ('u4', NULL)",
+	)
+}
+
+func TestUpdate_UniqueViolation(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e,
+		"CREATE TABLE users (id TEXT PRIMARY KEY, email TEXT UNIQUE)",
+		"INSERT INTO users (id, email) VALUES ('u1', 'alice@example.com')",
+		"INSERT INTO users (id, email) VALUES ('u2', 'bob@example.com')",
+	)
+
+	// Rewriting a row's own value is allowed
+	mustExec(t, e, "UPDATE users SET email = 'alice@example.com' WHERE id = 'u1'")
+
+	_, err := e.Execute("UPDATE users SET email = 'alice@example.com' WHERE id = 'u2'")
+	if err == nil || !strings.Contains(err.Error(), "unique column email") {
+		t.Fatalf("Expected unique violation, got %v", err)
+	}
+}
+
+func TestInsert_CompositeUniqueViolation(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e,
+		"CREATE TABLE accounts (id TEXT PRIMARY KEY, tenant_id TEXT, username TEXT, UNIQUE KEY uq_tenant_user (tenant_id, username))",
+		"INSERT INTO accounts (id, tenant_id, username) VALUES ('a1', 't1', 'alice')",
+		// Same username in another tenant is fine
+		"INSERT INTO accounts (id, tenant_id, username) VALUES ('a2', 't2', 'alice')",
+	)
+
+	table, err := e.store.Schema.GetTable("accounts")
+	if err != nil {
+		t.Fatalf("Failed to get table: %v", err)
+	}
+	if len(table.Unique) != 1 || strings.Join(table.Unique[0], ",") != "tenant_id,username" {
+		t.Fatalf("Expected composite unique constraint, got %v", table.Unique)
+	}
+
+	_, err = e.Execute("INSERT INTO accounts (id, tenant_id, username) VALUES ('a3', 't1', 'alice')")
+	if err == nil || !strings.Contains(err.Error(), "unique columns (tenant_id, username)") {
+		t.Fatalf("Expected composite unique violation, got %v", err)
+	}
+
+	// Moving a2 into t1 would collide with a1
+	_, err = e.Execute("UPDATE accounts SET tenant_id = 't1' WHERE id = 'a2'")
+	if err == nil || !strings.Contains(err.Error(), "unique columns (tenant_id, username)") {
+		t.Fatalf("Expected composite unique violation on update, got %v", err)
+	}
+}
+
+func TestCreateTable_PostgresConstraints(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e,
+		"CREATE TABLE accounts (id TEXT PRIMARY KEY, tenant_id TEXT, username TEXT, UNIQUE (tenant_id, username))",
+		"CREATE TABLE members (org_id TEXT, user_id TEXT, email TEXT, "+
+			"CONSTRAINT members_pkey PRIMARY KEY (org_id, user_id), CONSTRAINT uq_email UNIQUE (org_id, email))",
+	)
+
+	accounts, err := e.store.Schema.GetTable("accounts")
+	if err != nil {
+		t.Fatalf("Failed to get table: %v", err)
+	}
+	if len(accounts.Unique) != 1 || strings.Join(accounts.Unique[0], ",") != "tenant_id,username" {
+		t.Errorf("Expected composite unique constraint, got %v", accounts.Unique)
+	}
+
+	members, err := e.store.Schema.GetTable("members")
+	if err != nil {
+		t.Fatalf("Failed to get table: %v", err)
+	}
+	if pk := members.PrimaryKey(); strings.Join(pk, ",") != "org_id,user_id" {
+		t.Errorf("Expected composite primary key, got %v", pk)
+	}
+	if len(members.Unique) != 1 || strings.Join(members.Unique[0], ",") != "org_id,email" {
+		t.Errorf("Expected composite unique constraint, got %v", members.Unique)
+	}
+
+	// A string default that looks like a constraint is left alone
+	mustExec(t, e, "CREATE TABLE notes (id TEXT PRIMARY KEY, body TEXT DEFAULT 'x, UNIQUE (y)')")
+	notes, err := e.store.Schema.GetTable("notes")
+	if err != nil {
+		t.Fatalf("Failed to get table: %v", err)
+	}
+	if col, _ := notes.Column("body"); col.Default != "'x, UNIQUE (y)'" {
+		t.Errorf("Expected default to be unchanged, got %q", col.Default)
+	}
+}
+
+func TestCreateTable_ExportRoundTrip(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	original := &storage.Table{
+		Name: "accounts",
+		Columns: []storage.Column{
+			{Name: "tenant_id", Type: "text", PrimaryKey: true},
+			{Name: "account_id", Type: "text", PrimaryKey: true},
+			{Name: "username", Type: "text"},
+			{Name: "email", Type: "text", Unique: true},
+		},
+		Unique: [][]string{{"tenant_id", "username"}},
+	}
+
+	// Exported DDL can be loaded back into SmarterBase
+	mustExec(t, e, export.TableToDDL(original))
+
+	table, err := e.store.Schema.GetTable("accounts")
+	if err != nil {
+		t.Fatalf("Failed to get table: %v", err)
+	}
+	if pk := table.PrimaryKey(); strings.Join(pk, ",") != "tenant_id,account_id" {
+		t.Errorf("Expected composite primary key, got %v", pk)
+	}
+	if col, _ := table.Column("email"); !col.Unique {
+		t.Error("Expected email column to be unique")
+	}
+	if len(table.Unique) != 1 || strings.Join(table.Unique[0], ",") != "tenant_id,username" {
+		t.Errorf("Expected composite unique constraint, got %v", table.Unique)
+	}
+}
+
+func TestSelect_WhereComparison(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e,
+		"CREATE TABLE products (id TEXT PRIMARY KEY, name TEXT, price DECIMAL)",
+		"INSERT INTO products (id, name, price) VALUES ('p1', 'Widget', '9.99')",
+		"INSERT INTO products (id, name, price) VALUES ('p2', 'Gadget', '19.99')",
+		"INSERT INTO products (id, name, price) VALUES ('p3', 'Gizmo', '100')",
+		"INSERT INTO products (id, name) VALUES ('p4', 'Unpriced')",
+	)
+
+	tests := []struct {
+		where    string
+		expected []string
+	}{
+		// Numeric, not lexical: '100' > '19.99'
+		{"price > 10", []string{"p2", "p3"}},
+		{"price >= 19.99", []string{"p2", "p3"}},
+		{"price < 20", []string{"p1", "p2"}},
+		{"price <= 9.99", []string{"p1"}},
+		{"price > -1 AND price < 10", []string{"p1"}},
+		{"price BETWEEN 9 AND 20", []string{"p1", "p2"}},
+		{"price NOT BETWEEN 9 AND 20", []string{"p3"}},
+		{"name IN ('Widget', 'Gizmo')", []string{"p1", "p3"}},
+		{"name NOT IN ('Widget', 'Gizmo')", []string{"p2", "p4"}},
+		{"price IN (100)", []string{"p3"}},
+		// Equality is numeric too: '100' = 100.0
+		{"price = 100.0", []string{"p3"}},
+		{"price != 100.0", []string{"p1", "p2"}},
+		{"price IN (9.990, 100.00)", []string{"p1", "p3"}},
+		// A NULL member makes a miss unknown, so NOT IN matches nothing
+		{"name IN ('Widget', NULL)", []string{"p1"}},
+		{"name NOT IN ('Widget', NULL)", nil},
+		// Strings compare lexically
+		{"name < 'H'", []string{"p2", "p3"}},
+	}
+
+	for _, tt := range tests {
+		result := mustExec(t, e, "SELECT id FROM products WHERE "+tt.where)
+
+		var ids []string
+		for _, row := range result.Rows {
+			ids = append(ids, row[0])
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("WHERE %s: expected %v, got %v", tt.where, tt.expected, ids)
+		}
+	}
+}
+
+func TestMatchLike(t *testing.T) {
+	tests := []struct {
+		s, pattern string
+		expected   bool
+	}{
+		{"Introduction", "Intro%", true},
+		{"Outro", "Intro%", false},
+		{"report.pdf", "%.pdf", true},
+		{"report.pdf.bak", "%.pdf", false},
+		{"abc", "%b%", true},
+		{"abc", "a_c", true},
+		{"abbc", "a_c", false},
+		{"", "%", true},
+		{"", "_", false},
+		{"aXbXc", "a%b%c", true},
+		{"100%", "100\\%", true},
+		{"1000", "100\\%", false},
+		{"intro", "Intro%", false}, // case-sensitive
+	}
+
+	for _, tt := range tests {
+		if got := matchLike(tt.s, tt.pattern, '\\'); got != tt.expected {
+			t.Errorf("matchLike(%q, %q) = %v, expected %v", tt.s, tt.pattern, got, tt.expected)
+		}
+	}
+}
+
+func TestSelect_WhereLike(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e,
+		"CREATE TABLE articles (id TEXT PRIMARY KEY, title TEXT)",
+		"INSERT INTO articles (id, title) VALUES ('a1', 'Intro to Go'), ('a2', 'Advanced Go'), ('a3', 'Intro to SQL')",
+	)
+
+	result := mustExec(t, e, "SELECT id FROM articles WHERE title LIKE 'Intro%'")
+	if len(result.Rows) != 2 || result.Rows[0][0] != "a1" || result.Rows[1][0] != "a3" {
+		t.Errorf("Expected a1 and a3, got %v", result.Rows)
+	}
+
+	result = mustExec(t, e, "SELECT id FROM articles WHERE title NOT LIKE '%Go'")
+	if len(result.Rows) != 1 || result.Rows[0][0] != "a3" {
+		t.Errorf("Expected a3, got %v", result.Rows)
+	}
+}
+
+func TestSelect_WhereLikeEscape(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e,
+		"CREATE TABLE rates (id TEXT PRIMARY KEY, label TEXT)",
+		"INSERT INTO rates (id, label) VALUES ('r1', '100%'), ('r2', '1000'), ('r3', 'a_b'), ('r4', 'axb')",
+	)
+
+	tests := []struct {
+		where    string
+		expected []string
+	}{
+		// The literal backslash must itself be escaped to reach LIKE
+		{`label LIKE '100\\%'`, []string{"r1"}},
+		{`label LIKE 'a\\_b'`, []string{"r3"}},
+		{"label LIKE '100!%' ESCAPE '!'", []string{"r1"}},
+		{"label LIKE 'a#_b' ESCAPE '#'", []string{"r3"}},
+		{"label NOT LIKE '100!%' ESCAPE '!'", []string{"r2", "r3", "r4"}},
+		// Without escaping, % and _ are wildcards
+		{"label LIKE '100%'", []string{"r1", "r2"}},
+		{"label LIKE 'a_b'", []string{"r3", "r4"}},
+	}
+
+	for _, tt := range tests {
+		result := mustExec(t, e, "SELECT id FROM rates WHERE "+tt.where+" ORDER BY id")
+
+		var ids []string
+		for _, row := range result.Rows {
+			ids = append(ids, row[0])
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("WHERE %s: expected %v, got %v", tt.where, tt.expected, ids)
+		}
+	}
+
+	if _, err := e.Execute("SELECT id FROM rates WHERE label LIKE '100%' ESCAPE '!!'"); err == nil {
+		t.Error("Expected error for multi-character escape")
+	}
+}
+
+func TestSelect_Distinct(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e,
+		"CREATE TABLE articles (id TEXT PRIMARY KEY, category TEXT)",
+		"INSERT INTO articles (id, category) VALUES ('a1', 'go'), ('a2', 'sql'), ('a3', 'go'), ('a4', 'rust')",
+	)
+
+	result := mustExec(t, e, "SELECT DISTINCT category FROM articles")
+
+	var categories []string
+	for _, row := range result.Rows {
+		categories = append(categories, row[0])
+	}
+	if strings.Join(categories, ",") != "go,sql,rust" {
+		t.Errorf("Expected go,sql,rust in first-seen order, got %v", categories)
+	}
+	if result.Message != "SELECT 3" {
+		t.Errorf("Expected 'SELECT 3', got %q", result.Message)
+	}
+
+	// Rows whose values differ only in where a NUL falls are still distinct
+	mustExec(t, e,
+		"CREATE TABLE pairs (id TEXT PRIMARY KEY, a TEXT, b TEXT)",
+		"INSERT INTO pairs (id, a, b) VALUES ('p1', 'p\\0q', 'r'), ('p2', 'p', 'q\\0r')",
+	)
+	for _, sql := range []string{
+		"SELECT DISTINCT a, b FROM pairs",
+		"SELECT a, b, COUNT(*) FROM pairs GROUP BY a, b",
+	} {
+		if result := mustExec(t, e, sql); len(result.Rows) != 2 {
+			t.Errorf("%s: expected 2 rows, got %v", sql, result.Rows)
+		}
+	}
+}
+
+func TestInsert_NotNullViolation(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e,
+		"CREATE TABLE users (id TEXT PRIMARY KEY, email TEXT NOT NULL, name TEXT)",
+		"INSERT INTO users (id, email) VALUES ('u1', 'alice@example.com')",
+	)
+
+	for _, sql := range []string{
+		"INSERT INTO users (id, name) VALUES ('u2', 'Bob')",
+		"INSERT INTO users (id, email) VALUES ('u2', NULL)",
+		"UPDATE users SET email = NULL WHERE id = 'u1'",
+	} {
+		_, err := e.Execute(sql)
+		if err == nil || !strings.Contains(err.Error(), "violates not-null constraint") {
+			t.Errorf("%s: expected not-null violation, got %v", sql, err)
+		}
+	}
+
+	// Updating other columns leaves the NOT NULL column alone
+	mustExec(t, e, "UPDATE users SET name = 'Alice' WHERE id = 'u1'")
+}
+
+func TestSelect_WhereFilters(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e,
+		"CREATE TABLE users (id TEXT PRIMARY KEY, role TEXT, team TEXT)",
+		"INSERT INTO users (id, role, team) VALUES ('u1', 'admin', 'core')",
+		"INSERT INTO users (id, role, team) VALUES ('u2', 'member', 'core')",
+		"INSERT INTO users (id, role) VALUES ('u3', 'admin')",
+	)
+
+	tests := []struct {
+		where    string
+		expected []string
+	}{
+		{"role = 'admin'", []string{"u1", "u3"}},
+		{"'admin' = role", []string{"u1", "u3"}},
+		{"role IN ('member', 'guest')", []string{"u2"}},
+		{"team IS NULL", []string{"u3"}},
+		{"team IS NOT NULL", []string{"u1", "u2"}},
+		{"NOT role = 'admin'", []string{"u2"}},
+		{"(role = 'admin' OR role = 'member') AND team = 'core'", []string{"u1", "u2"}},
+		// NULL never equals or differs from a value
+		{"team = 'core'", []string{"u1", "u2"}},
+		{"team != 'core'", nil},
+	}
+
+	for _, tt := range tests {
+		result := mustExec(t, e, "SELECT id FROM users WHERE "+tt.where)
+
+		var ids []string
+		for _, row := range result.Rows {
+			ids = append(ids, row[0])
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("WHERE %s: expected %v, got %v", tt.where, tt.expected, ids)
+		}
+	}
+}
+
+func TestWhere_UnsupportedExpression(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e,
+		"CREATE TABLE users (id TEXT PRIMARY KEY, name TEXT)",
+		"INSERT INTO users (id, name) VALUES ('u1', 'Alice'), ('u2', 'Bob')",
+	)
+
+	// Unsupported filters must fail rather than match every row
+	for _, sql := range []string{
+		"SELECT id FROM users WHERE lower(name) = 'alice'",
+		"UPDATE users SET name = 'X' WHERE lower(name) = 'alice'",
+		"DELETE FROM users WHERE id = 'u1' OR lower(name) = 'bob'",
+	} {
+		if _, err := e.Execute(sql); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+
+	result := mustExec(t, e, "SELECT name FROM users WHERE name = 'X'")
+	if len(result.Rows) != 0 {
+		t.Errorf("Expected no rows updated, got %v", result.Rows)
+	}
+	count, _ := e.store.Data.Count("users")
+	if count != 2 {
+		t.Errorf("Expected 2 rows to remain, got %d", count)
+	}
+}
+
+func TestSelect_OrderByLimit(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e,
+		"CREATE TABLE products (id TEXT PRIMARY KEY, category TEXT, price DECIMAL)",
+		"INSERT INTO products (id, category, price) VALUES ('p1', 'b', '9.99')",
+		"INSERT INTO products (id, category, price) VALUES ('p2', 'a', '100')",
+		"INSERT INTO products (id, category, price) VALUES ('p3', 'b', '19.99')",
+		"INSERT INTO products (id, category) VALUES ('p4', 'a')",
+	)
+
+	tests := []struct {
+		clause   string
+		expected []string
+	}{
+		// Numeric order, NULLs last ascending and first descending
+		{"ORDER BY price", []string{"p1", "p3", "p2", "p4"}},
+		{"ORDER BY price DESC", []string{"p4", "p2", "p3", "p1"}},
+		{"ORDER BY category ASC, price DESC", []string{"p4", "p2", "p3", "p1"}},
+		{"ORDER BY price LIMIT 2", []string{"p1", "p3"}},
+		{"ORDER BY price LIMIT 2 OFFSET 1", []string{"p3", "p2"}},
+		{"ORDER BY price LIMIT 1, 2", []string{"p3", "p2"}},
+		{"ORDER BY price LIMIT 10 OFFSET 10", nil},
+		// Bound parameters arrive as quoted strings
+		{"ORDER BY price LIMIT '2' OFFSET '1'", []string{"p3", "p2"}},
+	}
+
+	for _, tt := range tests {
+		result := mustExec(t, e, "SELECT id FROM products "+tt.clause)
+
+		var ids []string
+		for _, row := range result.Rows {
+			ids = append(ids, row[0])
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("%s: expected %v, got %v", tt.clause, tt.expected, ids)
+		}
+	}
+
+	for _, sql := range []string{
+		"SELECT id FROM products ORDER BY lower(category)",
+		"SELECT id FROM products GROUP BY category HAVING category = 'a'",
+		"SELECT id FROM products LIMIT 'two'",
+		"SELECT id FROM products LIMIT '-1'",
+		"SELECT id FROM products LIMIT 1.5",
+	} {
+		if _, err := e.Execute(sql); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
+
+func TestSelect_Count(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e,
+		"CREATE TABLE orders (id TEXT PRIMARY KEY, state TEXT, coupon TEXT)",
+		"INSERT INTO orders (id, state, coupon) VALUES ('o1', 'shipped', 'SAVE10')",
+		"INSERT INTO orders (id, state) VALUES ('o2', 'pending')",
+		"INSERT INTO orders (id, state) VALUES ('o3', 'shipped')",
+		"INSERT INTO orders (id) VALUES ('o4')",
+	)
+
+	tests := []struct {
+		sql      string
+		columns  string
+		expected string
+	}{
+		{"SELECT COUNT(*) FROM orders", "count", "4"},
+		{"SELECT COUNT(*) FROM orders WHERE state = 'shipped'", "count", "2"},
+		{"SELECT COUNT(*) FROM orders WHERE state = 'cancelled'", "count", "0"},
+		{"SELECT COUNT(coupon) AS with_coupon FROM orders", "with_coupon", "1"},
+		// NULL forms its own group, rendered as an empty value
+		{"SELECT state, COUNT(*) FROM orders GROUP BY state", "state,count", "shipped:2|pending:1|:1"},
+		{"SELECT state, COUNT(*) AS n FROM orders GROUP BY state ORDER BY n DESC, state LIMIT 2", "state,n", "shipped:2|pending:1"},
+		// The NULL group sorts last ascending and first descending
+		{"SELECT state, COUNT(*) FROM orders GROUP BY state ORDER BY state", "state,count", "pending:1|shipped:2|:1"},
+		{"SELECT state, COUNT(*) FROM orders GROUP BY state ORDER BY state DESC", "state,count", ":1|shipped:2|pending:1"},
+		{"SELECT state FROM orders WHERE state IS NOT NULL GROUP BY state ORDER BY state", "state", "pending|shipped"},
+		// ORDER BY can use GROUP BY columns and counts that aren't selected
+		{"SELECT COUNT(*) FROM orders GROUP BY state ORDER BY state", "count", "1|2|1"},
+		{"SELECT state, COUNT(*) AS n FROM orders GROUP BY state ORDER BY COUNT(*) DESC, state", "state,n", "shipped:2|pending:1|:1"},
+		{"SELECT state FROM orders GROUP BY state ORDER BY COUNT(coupon) DESC, state", "state", "shipped|pending|"},
+	}
+
+	for _, tt := range tests {
+		result := mustExec(t, e, tt.sql)
+
+		var rows []string
+		for _, row := range result.Rows {
+			rows = append(rows, strings.Join(row, ":"))
+		}
+		if strings.Join(result.Columns, ",") != tt.columns {
+			t.Errorf("%s: expected columns %s, got %v", tt.sql, tt.columns, result.Columns)
+		}
+		if strings.Join(rows, "|") != tt.expected {
+			t.Errorf("%s: expected %s, got %v", tt.sql, tt.expected, rows)
+		}
+	}
+
+	for _, sql := range []string{
+		"SELECT id, COUNT(*) FROM orders",
+		"SELECT COUNT(DISTINCT state) FROM orders",
+		"SELECT SUM(id) FROM orders GROUP BY state",
+		"SELECT COUNT(*) FROM orders GROUP BY state ORDER BY id",
+	} {
+		if _, err := e.Execute(sql); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+}
+
+func TestStatementsAreAtomic(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e,
+		"CREATE TABLE p (id TEXT PRIMARY KEY, email TEXT UNIQUE, name TEXT NOT NULL)",
+		"INSERT INTO p (id, email, name) VALUES ('a', 'a@example.com', 'A'), ('b', 'b@example.com', 'B')",
+	)
+
+	for _, sql := range []string{
+		// 'c' is valid, 'd' duplicates a's email
+		"INSERT INTO p (id, email, name) VALUES ('c', 'c@example.com', 'C'), ('d', 'a@example.com', 'D')",
+		// 'e' is valid, 'f' has no name
+		"INSERT INTO p (id, email, name) VALUES ('e', 'e@example.com', 'E'), ('f', 'f@example.com', NULL)",
+		// Setting one email on both rows makes them collide with each other
+		"UPDATE p SET email = 'z@example.com'",
+	} {
+		if _, err := e.Execute(sql); err == nil {
+			t.Errorf("%s: expected error", sql)
+		}
+	}
+
+	result := mustExec(t, e, "SELECT id, email FROM p")
+	var rows []string
+	for _, row := range result.Rows {
+		rows = append(rows, strings.Join(row, "="))
+	}
+	expected := "a=a@example.com,b=b@example.com"
+	if strings.Join(rows, ",") != expected {
+		t.Errorf("Expected no changes after failed statements, got %v", rows)
+	}
+}
+
+func TestInsert_Defaults(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e,
+		"CREATE TABLE tasks (id TEXT PRIMARY KEY, state TEXT NOT NULL DEFAULT 'it''s new', priority INT DEFAULT 3, note TEXT DEFAULT NULL)",
+		"INSERT INTO tasks (id) VALUES ('t1')",
+		"INSERT INTO tasks (id, state, priority) VALUES ('t2', 'done', 1)",
+		// DEFAULT in VALUES takes the column default, not NULL
+		"INSERT INTO tasks (id, state, priority, note) VALUES ('t3', DEFAULT, DEFAULT, DEFAULT)",
+	)
+
+	result := mustExec(t, e, "SELECT id, state, priority, note FROM tasks ORDER BY id")
+	var rows []string
+	for _, row := range result.Rows {
+		rows = append(rows, strings.Join(row, "|"))
+	}
+	expected := "t1|it's new|3||t2|done|1||t3|it's new|3|"
+	if strings.Join(rows, "|") != expected {
+		t.Errorf("Expected %q, got %q", expected, strings.Join(rows, "|"))
+	}
+
+	// Expression defaults are not evaluated, so the column must be given
+	mustExec(t, e, "CREATE TABLE events (id TEXT PRIMARY KEY, at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP)")
+	if _, err := e.Execute("INSERT INTO events (id) VALUES ('e1')"); err == nil {
+		t.Error("Expected error for omitted column with expression default")
+	}
+	mustExec(t, e, "INSERT INTO events (id, at) VALUES ('e1', '2026-01-01')")
+}
+
+func TestInsert_Boolean(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	// Boolean columns come from imported PostgreSQL schemas
+	e.store.Schema.CreateTable(&storage.Table{
+		Name: "flags",
+		Columns: []storage.Column{
+			{Name: "id", Type: "text", PrimaryKey: true},
+			{Name: "active", Type: "boolean", NotNull: true},
+			{Name: "visible", Type: "boolean", Default: "true"},
+		},
+	})
+
+	mustExec(t, e,
+		"INSERT INTO flags (id, active, visible) VALUES ('f1', true, false)",
+		"INSERT INTO flags (id, active) VALUES ('f2', FALSE)",
+	)
+
+	result := mustExec(t, e, "SELECT id, active, visible FROM flags ORDER BY id")
+	var rows []string
+	for _, row := range result.Rows {
+		rows = append(rows, strings.Join(row, "|"))
+	}
+	expected := "f1|true|false|f2|false|true"
+	if strings.Join(rows, "|") != expected {
+		t.Errorf("Expected %q, got %q", expected, strings.Join(rows, "|"))
+	}
+}
+
+func TestSelect_WhereBoolean(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	e.store.Schema.CreateTable(&storage.Table{
+		Name: "flags",
+		Columns: []storage.Column{
+			{Name: "id", Type: "text", PrimaryKey: true},
+			{Name: "active", Type: "boolean"},
+		},
+	})
+	mustExec(t, e, "INSERT INTO flags (id, active) VALUES ('f1', true), ('f2', false), ('f3', NULL)")
+
+	tests := map[string]string{
+		"active = true":   "f1",
+		"active = FALSE":  "f2",
+		"active != false": "f1",
+		"true = active":   "f1",
+	}
+	for where, expected := range tests {
+		result := mustExec(t, e, "SELECT id FROM flags WHERE "+where)
+		if len(result.Rows) != 1 || result.Rows[0][0] != expected {
+			t.Errorf("WHERE %s: expected %s, got %v", where, expected, result.Rows)
+		}
+	}
+
+	result := mustExec(t, e, "UPDATE flags SET active = true WHERE active = false")
+	if result.RowsAffected != 1 {
+		t.Errorf("Expected 1 row updated, got %d", result.RowsAffected)
+	}
+}
+
+func TestSelect_WhereThreeValuedLogic(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e,
+		"CREATE TABLE items (id TEXT PRIMARY KEY, qty INT, tag TEXT)",
+		"INSERT INTO items (id, qty, tag) VALUES ('i1', 5, 'a')",
+		"INSERT INTO items (id, qty, tag) VALUES ('i2', 20, 'b')",
+		"INSERT INTO items (id) VALUES ('i3')",
+	)
+
+	tests := []struct {
+		where    string
+		expected []string
+	}{
+		// NOT of unknown stays unknown, so the NULL row never matches
+		{"NOT qty = 5", []string{"i2"}},
+		{"NOT tag IN ('a')", []string{"i2"}},
+		{"tag NOT IN ('a')", []string{"i2"}},
+		{"NOT qty BETWEEN 1 AND 10", []string{"i2"}},
+		{"qty NOT BETWEEN 1 AND 10", []string{"i2"}},
+		{"NOT tag LIKE 'a%'", []string{"i2"}},
+		{"NOT (qty > 10 AND tag = 'b')", []string{"i1"}},
+		// Unknown OR true is true; unknown AND false is false
+		{"qty = 5 OR id = 'i3'", []string{"i1", "i3"}},
+		{"NOT (qty = 5 AND id = 'i2')", []string{"i1", "i2", "i3"}},
+		{"NOT (qty = 5 AND id = 'i3')", []string{"i1", "i2"}},
+		{"NOT (qty = 5 OR id = 'i2')", nil},
+		// A NULL bound is unknown, unless the other bound already fails
+		{"qty BETWEEN 10 AND NULL", nil},
+		{"qty NOT BETWEEN 10 AND NULL", []string{"i1"}},
+	}
+
+	for _, tt := range tests {
+		result := mustExec(t, e, "SELECT id FROM items WHERE "+tt.where+" ORDER BY id")
+
+		var ids []string
+		for _, row := range result.Rows {
+			ids = append(ids, row[0])
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("WHERE %s: expected %v, got %v", tt.where, tt.expected, ids)
+		}
+	}
+}
+
+func TestWhere_TextLooksLikeNumber(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e,
+		"CREATE TABLE codes (id TEXT PRIMARY KEY, code TEXT UNIQUE, qty INT)",
+		"INSERT INTO codes (id, code, qty) VALUES ('c1', '007', '9'), ('c2', '7', '10'), ('c3', '7.0', '1a')",
+		"INSERT INTO codes (id, code, qty) VALUES ('c4', 'NaN', 'NaN'), ('c5', 'Infinity', 'Infinity')",
+	)
+
+	tests := []struct {
+		sql      string
+		expected []string
+	}{
+		// TEXT compares as text, matching what UNIQUE considers distinct
+		{"SELECT id FROM codes WHERE code = '7'", []string{"c2"}},
+		{"SELECT id FROM codes WHERE code IN ('7')", []string{"c2"}},
+		{"SELECT id FROM codes WHERE code != '7' ORDER BY id", []string{"c1", "c3", "c4", "c5"}},
+		{"SELECT id FROM codes WHERE code > '7' ORDER BY id", []string{"c3", "c4", "c5"}},
+		{"SELECT id FROM codes ORDER BY code", []string{"c1", "c2", "c3", "c5", "c4"}},
+		// NaN and infinities are not numbers, so they match only themselves
+		{"SELECT id FROM codes WHERE code = 'NaN'", []string{"c4"}},
+		{"SELECT id FROM codes WHERE code = 'inf'", nil},
+		{"SELECT id FROM codes WHERE qty = 'NaN'", []string{"c4"}},
+		{"SELECT id FROM codes WHERE qty = 10", []string{"c2"}},
+		// Numeric columns sort numbers first, then anything else as text
+		{"SELECT id FROM codes ORDER BY qty", []string{"c1", "c2", "c3", "c5", "c4"}},
+	}
+
+	for _, tt := range tests {
+		result := mustExec(t, e, tt.sql)
+
+		var ids []string
+		for _, row := range result.Rows {
+			ids = append(ids, row[0])
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("%s: expected %v, got %v", tt.sql, tt.expected, ids)
+		}
+	}
+
+	// Deleting one code must not take the others with it
+	result := mustExec(t, e, "DELETE FROM codes WHERE code = '7'")
+	if result.RowsAffected != 1 {
+		t.Errorf("Expected 1 row deleted, got %d", result.RowsAffected)
+	}
+}
+
+func TestCompositePrimaryKey(t *testing.T) {
+	e := setupTestExecutor(t)
+
+	mustExec(t, e,
+		"CREATE TABLE m (id TEXT, org_id TEXT, user_id TEXT, PRIMARY KEY (org_id, user_id))",
+		"INSERT INTO m (org_id, user_id) VALUES ('o1', 'u1')",
+		"INSERT INTO m (org_id, user_id) VALUES ('o1', 'u2')",
+	)
+
+	if _, err := e.Execute("INSERT INTO m (org_id, user_id) VALUES ('o1', 'u1')"); err == nil {
+		t.Error("Expected duplicate key error")
+	}
+	if _, err := e.Execute("UPDATE m SET user_id = 'u1' WHERE user_id = 'u2'"); err == nil {
+		t.Error("Expected duplicate key error on update")
+	}
+}
@@ -0,0 +1,197 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/adrianmcphee/smarterbase/internal/storage"
+)
+
+// ExportCSV writes a table's rows as CSV with a header row. Columns follow
+// schema order, followed by any extra keys found in the data (sorted). Nested
+// JSON objects are flattened into dot-separated columns such as profile.city.
+func ExportCSV(store *storage.Store, tableName string, w io.Writer) error {
+	table, err := store.Schema.GetTable(tableName)
+	if err != nil {
+		return err
+	}
+
+	rows, err := store.Data.Scan(tableName)
+	if err != nil {
+		return err
+	}
+
+	// Flatten every row first so the header covers the union of keys
+	flatRows := make([]map[string]string, len(rows))
+	seen := make(map[string]bool)
+	var extra []string
+	for i, row := range rows {
+		flat := make(map[string]string)
+		flattenValue("", map[string]any(row), flat)
+		flatRows[i] = flat
+		for key := range flat {
+			if !seen[key] {
+				seen[key] = true
+				extra = append(extra, key)
+			}
+		}
+	}
+
+	// Schema columns first; a nested column is replaced by its flattened keys
+	var header []string
+	used := make(map[string]bool)
+	for _, col := range table.Columns {
+		var nested []string
+		for _, key := range extra {
+			if strings.HasPrefix(key, col.Name+".") {
+				nested = append(nested, key)
+			}
+		}
+		if len(nested) > 0 && !seen[col.Name] {
+			sort.Strings(nested)
+			for _, key := range nested {
+				header = append(header, key)
+				used[key] = true
+			}
+			continue
+		}
+		header = append(header, col.Name)
+		used[col.Name] = true
+	}
+
+	sort.Strings(extra)
+	for _, key := range extra {
+		if !used[key] {
+			header = append(header, key)
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+
+	record := make([]string, len(header))
+	for _, flat := range flatRows {
+		for i, key := range header {
+			record[i] = flat[key]
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("write row: %w", err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// flattenValue renders v into out, descending into objects with dotted keys
+func flattenValue(key string, v any, out map[string]string) {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, child := range val {
+			if key != "" {
+				k = key + "." + k
+			}
+			flattenValue(k, child, out)
+		}
+	case nil:
+		out[key] = ""
+	case string:
+		out[key] = val
+	case float64:
+		out[key] = strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		out[key] = strconv.FormatBool(val)
+	default:
+		// Arrays and anything else keep their JSON form
+		data, err := json.Marshal(val)
+		if err != nil {
+			out[key] = fmt.Sprintf("%v", val)
+			return
+		}
+		out[key] = string(data)
+	}
+}
+
+// ImportCSV reads CSV with a header row and inserts each record into a table.
+// Empty cells are treated as NULL and omitted. Dotted headers such as
+// profile.city are rebuilt into nested objects when profile is a column.
+// Values are stored as strings, the same as literals inserted via SQL.
+// Returns the number of rows inserted; nothing is written if any row fails.
+func ImportCSV(store *storage.Store, tableName string, r io.Reader) (int, error) {
+	table, err := store.Schema.GetTable(tableName)
+	if err != nil {
+		return 0, err
+	}
+
+	columns := make(map[string]bool, len(table.Columns))
+	for _, col := range table.Columns {
+		columns[col.Name] = true
+	}
+
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return 0, fmt.Errorf("empty CSV: missing header row")
+		}
+		return 0, fmt.Errorf("read header: %w", err)
+	}
+
+	var rows []storage.Row
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("read row %d: %w", len(rows)+1, err)
+		}
+
+		row := make(storage.Row)
+		for i, key := range header {
+			if i >= len(record) || record[i] == "" {
+				continue
+			}
+			setNested(row, key, record[i], columns)
+		}
+		rows = append(rows, row)
+	}
+
+	if _, err := store.Data.InsertBatch(tableName, rows); err != nil {
+		return 0, err
+	}
+
+	return len(rows), nil
+}
+
+// setNested stores value under key, expanding a dotted key into nested
+// objects when its first segment is a table column
+func setNested(row storage.Row, key, value string, columns map[string]bool) {
+	parts := strings.Split(key, ".")
+	if columns[key] || len(parts) == 1 || !columns[parts[0]] {
+		row[key] = value
+		return
+	}
+
+	obj, ok := row[parts[0]].(map[string]any)
+	if !ok {
+		obj = make(map[string]any)
+		row[parts[0]] = obj
+	}
+	for _, part := range parts[1 : len(parts)-1] {
+		child, ok := obj[part].(map[string]any)
+		if !ok {
+			child = make(map[string]any)
+			obj[part] = child
+		}
+		obj = child
+	}
+	obj[parts[len(parts)-1]] = value
+}
@@ -0,0 +1,138 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/adrianmcphee/smarterbase/internal/storage"
+)
+
+func TestExportCSV_HeaderAndRows(t *testing.T) {
+	store, _ := setupTestStore(t)
+
+	store.Schema.CreateTable(&storage.Table{
+		Name: "users",
+		Columns: []storage.Column{
+			{Name: "id", Type: "text", PrimaryKey: true},
+			{Name: "name", Type: "text"},
+			{Name: "profile", Type: "jsonb"},
+			{Name: "age", Type: "int"},
+		},
+	})
+
+	store.Data.Insert("users", storage.Row{
+		"id":      "u1",
+		"name":    "Alice, Jr.",
+		"profile": map[string]any{"city": "Berlin", "zip": "10115"},
+		"age":     float64(30),
+	})
+	store.Data.Insert("users", storage.Row{
+		"id":   "u2",
+		"name": "Bob",
+	})
+
+	var buf bytes.Buffer
+	if err := ExportCSV(store, "users", &buf); err != nil {
+		t.Fatalf("ExportCSV failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected header + 2 rows, got:\n%s", buf.String())
+	}
+
+	// Nested profile is flattened in place of the column
+	if lines[0] != "id,name,profile.city,profile.zip,age" {
+		t.Errorf("Unexpected header: %s", lines[0])
+	}
+	if lines[1] != `u1,"Alice, Jr.",Berlin,10115,30` {
+		t.Errorf("Unexpected row 1: %s", lines[1])
+	}
+	if lines[2] != "u2,Bob,,," {
+		t.Errorf("Unexpected row 2: %s", lines[2])
+	}
+}
+
+func TestExportCSV_UnknownTable(t *testing.T) {
+	store, _ := setupTestStore(t)
+
+	var buf bytes.Buffer
+	if err := ExportCSV(store, "missing", &buf); err == nil {
+		t.Error("Expected error for unknown table")
+	}
+}
+
+func TestImportCSV_RoundTrip(t *testing.T) {
+	store, _ := setupTestStore(t)
+
+	store.Schema.CreateTable(&storage.Table{
+		Name: "users",
+		Columns: []storage.Column{
+			{Name: "id", Type: "text", PrimaryKey: true},
+			{Name: "name", Type: "text"},
+			{Name: "profile", Type: "jsonb"},
+		},
+	})
+
+	input := "id,name,profile.city\nu1,\"Alice, Jr.\",Berlin\nu2,Bob,\n"
+	count, err := ImportCSV(store, "users", strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ImportCSV failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 rows imported, got %d", count)
+	}
+
+	row, err := store.Data.Get("users", "u1")
+	if err != nil {
+		t.Fatalf("Failed to get u1: %v", err)
+	}
+	if row["name"] != "Alice, Jr." {
+		t.Errorf("Expected name 'Alice, Jr.', got %v", row["name"])
+	}
+	profile, ok := row["profile"].(map[string]any)
+	if !ok || profile["city"] != "Berlin" {
+		t.Errorf("Expected nested profile.city, got %v", row["profile"])
+	}
+
+	// Empty cells are NULL and omitted
+	row, err = store.Data.Get("users", "u2")
+	if err != nil {
+		t.Fatalf("Failed to get u2: %v", err)
+	}
+	if _, ok := row["profile"]; ok {
+		t.Errorf("Expected no profile for u2, got %v", row["profile"])
+	}
+
+	// Exporting gives back the same CSV
+	var buf bytes.Buffer
+	if err := ExportCSV(store, "users", &buf); err != nil {
+		t.Fatalf("ExportCSV failed: %v", err)
+	}
+	if buf.String() != input {
+		t.Errorf("Round trip mismatch:\n%s\nvs\n%s", buf.String(), input)
+	}
+}
+
+func TestImportCSV_AllOrNothing(t *testing.T) {
+	store, _ := setupTestStore(t)
+
+	store.Schema.CreateTable(&storage.Table{
+		Name: "users",
+		Columns: []storage.Column{
+			{Name: "id", Type: "text", PrimaryKey: true},
+			{Name: "email", Type: "text", Unique: true},
+		},
+	})
+
+	input := "id,email\nu1,a@example.com\nu2,a@example.com\n"
+	if _, err := ImportCSV(store, "users", strings.NewReader(input)); err == nil {
+		t.Fatal("Expected unique violation")
+	}
+
+	count, _ := store.Data.Count("users")
+	if count != 0 {
+		t.Errorf("Expected no rows after failed import, got %d", count)
+	}
+}
@@ -1,4 +1,5 @@
-// Package export generates PostgreSQL DDL from SmarterBase schemas.
+// Package export converts SmarterBase schemas and data to and from other
+// formats: PostgreSQL DDL and INSERT statements, CSV, and JSON.
 package export
 
 import (
@@ -39,10 +40,30 @@ func TableToDDL(table *storage.Table) string {
 
 	sb.WriteString(fmt.Sprintf("CREATE TABLE %s (\n", table.Name))
 
-	for i, col := range table.Columns {
+	// A composite primary key is a table constraint; a single column keeps
+	// PRIMARY KEY inline
+	pk := table.PrimaryKey()
+	composite := len(pk) > 1
+
+	lines := make([]string, 0, len(table.Columns)+len(table.Unique)+1)
+	for _, col := range table.Columns {
+		if composite && col.PrimaryKey {
+			col.PrimaryKey = false
+			col.NotNull = true
+		}
+		lines = append(lines, columnToDDL(&col))
+	}
+	if composite {
+		lines = append(lines, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(pk, ", ")))
+	}
+	for _, cols := range table.Unique {
+		lines = append(lines, fmt.Sprintf("UNIQUE (%s)", strings.Join(cols, ", ")))
+	}
+
+	for i, line := range lines {
 		sb.WriteString("  ")
-		sb.WriteString(columnToDDL(&col))
-		if i < len(table.Columns)-1 {
+		sb.WriteString(line)
+		if i < len(lines)-1 {
 			sb.WriteString(",")
 		}
 		sb.WriteString("\n")
@@ -266,6 +266,42 @@ func TestTableToDDL_WithDefault(t *testing.T) {
 	}
 }
 
+func TestTableToDDL_CompositeUnique(t *testing.T) {
+	table := &storage.Table{
+		Name: "accounts",
+		Columns: []storage.Column{
+			{Name: "id", Type: "uuid", PrimaryKey: true},
+			{Name: "tenant_id", Type: "uuid"},
+			{Name: "username", Type: "text"},
+		},
+		Unique: [][]string{{"tenant_id", "username"}},
+	}
+
+	output := TableToDDL(table)
+
+	if !strings.Contains(output, "username TEXT,\n  UNIQUE (tenant_id, username)\n);") {
+		t.Errorf("Expected composite UNIQUE constraint in output:\n%s", output)
+	}
+}
+
+func TestTableToDDL_CompositePrimaryKey(t *testing.T) {
+	table := &storage.Table{
+		Name: "memberships",
+		Columns: []storage.Column{
+			{Name: "org_id", Type: "text", PrimaryKey: true},
+			{Name: "user_id", Type: "text", PrimaryKey: true},
+			{Name: "role", Type: "text"},
+		},
+	}
+
+	output := TableToDDL(table)
+
+	expected := "  org_id TEXT NOT NULL,\n  user_id TEXT NOT NULL,\n  role TEXT,\n  PRIMARY KEY (org_id, user_id)\n);"
+	if !strings.Contains(output, expected) {
+		t.Errorf("Expected composite PRIMARY KEY constraint in output:\n%s", output)
+	}
+}
+
 // TestExportIntegration tests the full workflow: create via SQL, export, verify valid PostgreSQL
 func TestExportIntegration(t *testing.T) {
 	store, dir := setupTestStore(t)
@@ -0,0 +1,101 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/adrianmcphee/smarterbase/internal/storage"
+)
+
+// KeyField is the field added to exported JSON documents holding the row's
+// storage key, "<table>/<id>"
+const KeyField = "_key"
+
+// ExportNDJSON streams rows as newline-delimited JSON, one object per line.
+// tables selects which tables to export; all tables are exported if empty.
+func ExportNDJSON(store *storage.Store, tables []string, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	err := eachDocument(store, tables, func(doc []byte) error {
+		if _, err := bw.Write(doc); err != nil {
+			return err
+		}
+		return bw.WriteByte('\n')
+	})
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// ExportJSON streams rows as a single JSON array. tables selects which tables
+// to export; all tables are exported if empty.
+func ExportJSON(store *storage.Store, tables []string, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	if _, err := bw.WriteString("["); err != nil {
+		return err
+	}
+
+	count := 0
+	err := eachDocument(store, tables, func(doc []byte) error {
+		sep := ",\n"
+		if count == 0 {
+			sep = "\n"
+		}
+		count++
+		if _, err := bw.WriteString(sep); err != nil {
+			return err
+		}
+		_, err := bw.Write(doc)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		if _, err := bw.WriteString("\n"); err != nil {
+			return err
+		}
+	}
+	if _, err := bw.WriteString("]\n"); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// eachDocument encodes every row of the given tables (sorted, or all tables
+// if none given) with its storage key and passes it to fn
+func eachDocument(store *storage.Store, tables []string, fn func([]byte) error) error {
+	if len(tables) == 0 {
+		tables = store.Schema.ListTables()
+		sort.Strings(tables) // Deterministic output
+	}
+
+	for _, tableName := range tables {
+		err := store.Data.ScanFunc(tableName, func(row storage.Row) error {
+			doc := make(map[string]any, len(row)+1)
+			for k, v := range row {
+				doc[k] = v
+			}
+			doc[KeyField] = fmt.Sprintf("%s/%v", tableName, row["id"])
+
+			data, err := json.Marshal(doc)
+			if err != nil {
+				return fmt.Errorf("encode row in %s: %w", tableName, err)
+			}
+			return fn(data)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
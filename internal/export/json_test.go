@@ -0,0 +1,96 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/adrianmcphee/smarterbase/internal/storage"
+)
+
+func setupJSONStore(t *testing.T) *storage.Store {
+	t.Helper()
+
+	store, _ := setupTestStore(t)
+	for _, name := range []string{"users", "posts"} {
+		store.Schema.CreateTable(&storage.Table{
+			Name: name,
+			Columns: []storage.Column{
+				{Name: "id", Type: "text", PrimaryKey: true},
+				{Name: "title", Type: "text"},
+			},
+		})
+	}
+	store.Data.Insert("users", storage.Row{"id": "u1", "title": "Alice"})
+	store.Data.Insert("users", storage.Row{"id": "u2", "title": "Bob"})
+	store.Data.Insert("posts", storage.Row{"id": "p1", "title": "Hello"})
+
+	return store
+}
+
+func TestExportNDJSON(t *testing.T) {
+	store := setupJSONStore(t)
+
+	var buf bytes.Buffer
+	if err := ExportNDJSON(store, nil, &buf); err != nil {
+		t.Fatalf("ExportNDJSON failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := []string{
+		`{"_key":"posts/p1","id":"p1","title":"Hello"}`,
+		`{"_key":"users/u1","id":"u1","title":"Alice"}`,
+		`{"_key":"users/u2","id":"u2","title":"Bob"}`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected NDJSON:\n%s", buf.String())
+	}
+
+	// A single table
+	buf.Reset()
+	if err := ExportNDJSON(store, []string{"posts"}, &buf); err != nil {
+		t.Fatalf("ExportNDJSON failed: %v", err)
+	}
+	if buf.String() != expected[0]+"\n" {
+		t.Errorf("Unexpected NDJSON for posts:\n%s", buf.String())
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	store := setupJSONStore(t)
+
+	var buf bytes.Buffer
+	if err := ExportJSON(store, []string{"users"}, &buf); err != nil {
+		t.Fatalf("ExportJSON failed: %v", err)
+	}
+
+	var docs []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &docs); err != nil {
+		t.Fatalf("Output is not a JSON array: %v\n%s", err, buf.String())
+	}
+	if len(docs) != 2 || docs[0]["_key"] != "users/u1" || docs[1]["title"] != "Bob" {
+		t.Errorf("Unexpected documents: %v", docs)
+	}
+}
+
+func TestExportJSON_Empty(t *testing.T) {
+	store, _ := setupTestStore(t)
+
+	var buf bytes.Buffer
+	if err := ExportJSON(store, nil, &buf); err != nil {
+		t.Fatalf("ExportJSON failed: %v", err)
+	}
+	if buf.String() != "[]\n" {
+		t.Errorf("Expected empty array, got %q", buf.String())
+	}
+}
+
+func TestExportNDJSON_UnknownTable(t *testing.T) {
+	store, _ := setupTestStore(t)
+
+	var buf bytes.Buffer
+	if err := ExportNDJSON(store, []string{"missing"}, &buf); err == nil {
+		t.Error("Expected error for unknown table")
+	}
+}
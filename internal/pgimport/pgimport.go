@@ -0,0 +1,157 @@
+// Package pgimport copies tables from a PostgreSQL database into SmarterBase.
+package pgimport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/adrianmcphee/smarterbase/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// Options configures a PostgreSQL import
+type Options struct {
+	DSN       string
+	Schema    string // PostgreSQL schema, defaults to "public"
+	Table     string
+	BatchSize int
+}
+
+// columnsQuery lists a table's columns in order, flagging primary key columns
+const columnsQuery = `
+SELECT c.column_name::text, c.data_type::text, c.is_nullable = 'YES',
+       EXISTS (
+         SELECT 1
+         FROM information_schema.table_constraints tc
+         JOIN information_schema.key_column_usage kcu
+           ON kcu.constraint_name = tc.constraint_name
+          AND kcu.table_schema = tc.table_schema
+          AND kcu.table_name = tc.table_name
+         WHERE tc.constraint_type = 'PRIMARY KEY'
+           AND tc.table_schema = c.table_schema
+           AND tc.table_name = c.table_name
+           AND kcu.column_name = c.column_name
+       )
+FROM information_schema.columns c
+WHERE c.table_schema = $1 AND c.table_name = $2
+ORDER BY c.ordinal_position`
+
+// Import copies a PostgreSQL table into the store. The SmarterBase table is
+// created from the source columns if it does not exist yet. Rows already
+// present (by primary key) are skipped, so a failed import can be re-run.
+func Import(ctx context.Context, store *storage.Store, opts Options) (ImportStats, error) {
+	var stats ImportStats
+
+	schema := opts.Schema
+	if schema == "" {
+		schema = "public"
+	}
+
+	conn, err := pgx.Connect(ctx, opts.DSN)
+	if err != nil {
+		return stats, fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	columns, err := readColumns(ctx, conn, schema, opts.Table)
+	if err != nil {
+		return stats, fmt.Errorf("read columns: %w", err)
+	}
+	if len(columns) == 0 {
+		return stats, fmt.Errorf("table %s.%s not found", schema, opts.Table)
+	}
+
+	if !store.Schema.TableExists(opts.Table) {
+		table, err := TableFromPostgres(opts.Table, columns)
+		if err != nil {
+			return stats, err
+		}
+		if err := store.Schema.CreateTable(table); err != nil {
+			return stats, err
+		}
+	}
+
+	// Cast every column to text so values match what SQL inserts store
+	selects := make([]string, len(columns))
+	for i, col := range columns {
+		selects[i] = pgx.Identifier{col.Name}.Sanitize() + "::text"
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s",
+		strings.Join(selects, ", "),
+		pgx.Identifier{schema, opts.Table}.Sanitize())
+
+	rows, err := conn.Query(ctx, query)
+	if err != nil {
+		return stats, fmt.Errorf("select rows: %w", err)
+	}
+	defer rows.Close()
+
+	return ImportRows(store, opts.Table, &rowSource{rows: rows, columns: columns}, opts.BatchSize)
+}
+
+// readColumns reads column definitions from information_schema
+func readColumns(ctx context.Context, conn *pgx.Conn, schema, table string) ([]SourceColumn, error) {
+	rows, err := conn.Query(ctx, columnsQuery, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []SourceColumn
+	for rows.Next() {
+		var col SourceColumn
+		if err := rows.Scan(&col.Name, &col.DataType, &col.Nullable, &col.PrimaryKey); err != nil {
+			return nil, err
+		}
+		columns = append(columns, col)
+	}
+
+	return columns, rows.Err()
+}
+
+// rowSource adapts pgx rows of text values to RowSource
+type rowSource struct {
+	rows    pgx.Rows
+	columns []SourceColumn
+}
+
+// Next returns the next row, omitting NULL columns. JSON columns are decoded
+// so they are stored as nested objects rather than strings.
+func (s *rowSource) Next() (storage.Row, error) {
+	if !s.rows.Next() {
+		if err := s.rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+
+	values := make([]*string, len(s.columns))
+	dest := make([]any, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := s.rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	row := make(storage.Row, len(s.columns))
+	for i, col := range s.columns {
+		if values[i] == nil {
+			continue
+		}
+
+		if col.DataType == "json" || col.DataType == "jsonb" {
+			var decoded any
+			if err := json.Unmarshal([]byte(*values[i]), &decoded); err == nil {
+				row[col.Name] = decoded
+				continue
+			}
+		}
+		row[col.Name] = *values[i]
+	}
+
+	return row, nil
+}
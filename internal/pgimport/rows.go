@@ -0,0 +1,185 @@
+package pgimport
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/adrianmcphee/smarterbase/internal/storage"
+)
+
+// SourceColumn describes a column of a PostgreSQL table, as read from
+// information_schema
+type SourceColumn struct {
+	Name       string
+	DataType   string
+	Nullable   bool
+	PrimaryKey bool
+}
+
+// RowSource yields rows to import one at a time. Next returns io.EOF once
+// there are no more rows.
+type RowSource interface {
+	Next() (storage.Row, error)
+}
+
+// ImportStats summarises an import run
+type ImportStats struct {
+	Imported int
+	Skipped  int
+}
+
+// TableFromPostgres builds a SmarterBase schema from PostgreSQL column info.
+// Every SmarterBase row needs an id, so one is added if the source has none.
+// The source must have an id column or a primary key, since that is how a
+// re-run import recognises rows it has already copied.
+func TableFromPostgres(name string, columns []SourceColumn) (*storage.Table, error) {
+	table := &storage.Table{Name: name}
+
+	hasID, hasKey := false, false
+	for _, col := range columns {
+		if col.Name == "id" {
+			hasID = true
+		}
+		if col.PrimaryKey {
+			hasKey = true
+		}
+	}
+	if !hasID && !hasKey {
+		return nil, fmt.Errorf("table %s has neither an id column nor a primary key, so imported rows can't be matched on re-run", name)
+	}
+	if !hasID {
+		table.Columns = append(table.Columns, storage.Column{Name: "id", Type: "text"})
+	}
+
+	for _, col := range columns {
+		table.Columns = append(table.Columns, storage.Column{
+			Name:       col.Name,
+			Type:       unmapType(col.DataType),
+			PrimaryKey: col.PrimaryKey,
+			NotNull:    !col.Nullable && !col.PrimaryKey,
+		})
+	}
+	return table, nil
+}
+
+// unmapType maps PostgreSQL information_schema data types to SmarterBase
+// types; the inverse of the export package's type mapping
+func unmapType(pgType string) string {
+	switch strings.ToLower(pgType) {
+	case "uuid":
+		return "uuid"
+	case "smallint", "integer":
+		return "integer"
+	case "bigint":
+		return "bigint"
+	case "boolean":
+		return "boolean"
+	case "numeric", "real", "double precision":
+		return "decimal"
+	case "timestamp with time zone", "timestamp without time zone":
+		return "timestamp"
+	case "date":
+		return "date"
+	case "json", "jsonb":
+		return "jsonb"
+	default:
+		return "text"
+	}
+}
+
+// ImportRows copies rows from src into an existing table, writing in batches
+// of batchSize. Rows whose primary key already exists in the table are
+// skipped, so an interrupted import can be re-run and resumes where it left
+// off. Each batch is all-or-nothing. A row without a key value is an error,
+// since it could not be recognised on a re-run.
+func ImportRows(store *storage.Store, tableName string, src RowSource, batchSize int) (ImportStats, error) {
+	var stats ImportStats
+
+	table, err := store.Schema.GetTable(tableName)
+	if err != nil {
+		return stats, err
+	}
+
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	// Rows are keyed by their primary key columns, or id if none are marked
+	keyCols := table.PrimaryKey()
+	if len(keyCols) == 0 {
+		keyCols = []string{"id"}
+	}
+
+	existing, err := store.Data.Scan(tableName)
+	if err != nil {
+		return stats, err
+	}
+	seen := make(map[string]bool, len(existing))
+	for _, row := range existing {
+		if key, ok := rowKey(row, keyCols); ok {
+			seen[key] = true
+		}
+	}
+
+	batch := make([]storage.Row, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if _, err := store.Data.InsertBatch(tableName, batch); err != nil {
+			return err
+		}
+		stats.Imported += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		row, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, fmt.Errorf("read row: %w", err)
+		}
+
+		key, ok := rowKey(row, keyCols)
+		if !ok {
+			return stats, fmt.Errorf("row has no value for key column(s) %s", strings.Join(keyCols, ", "))
+		}
+		if seen[key] {
+			stats.Skipped++
+			continue
+		}
+		seen[key] = true
+
+		batch = append(batch, row)
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// rowKey encodes a row's primary key values, each length-prefixed so no two
+// keys collide; false if any is missing
+func rowKey(row storage.Row, cols []string) (string, bool) {
+	var key strings.Builder
+	for _, col := range cols {
+		val, ok := row[col]
+		if !ok || val == nil {
+			return "", false
+		}
+		s := fmt.Sprintf("%v", val)
+		fmt.Fprintf(&key, "%d:%s", len(s), s)
+	}
+	return key.String(), true
+}
@@ -0,0 +1,216 @@
+package pgimport
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/adrianmcphee/smarterbase/internal/storage"
+)
+
+func setupTestStore(t *testing.T) *storage.Store {
+	t.Helper()
+
+	store, err := storage.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	return store
+}
+
+// sliceSource yields rows from a slice, optionally failing after failAfter rows
+type sliceSource struct {
+	rows      []storage.Row
+	failAfter int
+	pos       int
+}
+
+func (s *sliceSource) Next() (storage.Row, error) {
+	if s.failAfter > 0 && s.pos == s.failAfter {
+		return nil, errors.New("connection reset")
+	}
+	if s.pos >= len(s.rows) {
+		return nil, io.EOF
+	}
+	row := s.rows[s.pos]
+	s.pos++
+	return row, nil
+}
+
+func TestTableFromPostgres(t *testing.T) {
+	table, err := TableFromPostgres("orders", []SourceColumn{
+		{Name: "order_id", DataType: "bigint", PrimaryKey: true},
+		{Name: "total", DataType: "numeric", Nullable: false},
+		{Name: "meta", DataType: "jsonb", Nullable: true},
+		{Name: "note", DataType: "character varying", Nullable: true},
+	})
+	if err != nil {
+		t.Fatalf("TableFromPostgres failed: %v", err)
+	}
+
+	expected := []storage.Column{
+		{Name: "id", Type: "text"},
+		{Name: "order_id", Type: "bigint", PrimaryKey: true},
+		{Name: "total", Type: "decimal", NotNull: true},
+		{Name: "meta", Type: "jsonb"},
+		{Name: "note", Type: "text"},
+	}
+	if len(table.Columns) != len(expected) {
+		t.Fatalf("Expected %d columns, got %d", len(expected), len(table.Columns))
+	}
+	for i, col := range expected {
+		if table.Columns[i] != col {
+			t.Errorf("Column %d: expected %+v, got %+v", i, col, table.Columns[i])
+		}
+	}
+}
+
+func TestTableFromPostgres_NoKey(t *testing.T) {
+	_, err := TableFromPostgres("events", []SourceColumn{
+		{Name: "name", DataType: "text", Nullable: true},
+	})
+	if err == nil {
+		t.Error("Expected error for table with neither id nor primary key")
+	}
+
+	// An id column is enough, even without a primary key
+	if _, err := TableFromPostgres("events", []SourceColumn{{Name: "id", DataType: "uuid"}}); err != nil {
+		t.Errorf("Expected id column to be accepted, got %v", err)
+	}
+}
+
+func TestUnmapType(t *testing.T) {
+	tests := map[string]string{
+		"uuid":                        "uuid",
+		"smallint":                    "integer",
+		"INTEGER":                     "integer",
+		"bigint":                      "bigint",
+		"boolean":                     "boolean",
+		"numeric":                     "decimal",
+		"double precision":            "decimal",
+		"timestamp with time zone":    "timestamp",
+		"timestamp without time zone": "timestamp",
+		"date":                        "date",
+		"json":                        "jsonb",
+		"character varying":           "text",
+		"bytea":                       "text",
+	}
+
+	for pgType, expected := range tests {
+		if got := unmapType(pgType); got != expected {
+			t.Errorf("unmapType(%q) = %q, expected %q", pgType, got, expected)
+		}
+	}
+}
+
+func TestImportRows_ResumesAfterFailure(t *testing.T) {
+	store := setupTestStore(t)
+
+	table, err := TableFromPostgres("orders", []SourceColumn{
+		{Name: "order_id", DataType: "bigint", PrimaryKey: true},
+		{Name: "total", DataType: "numeric", Nullable: true},
+	})
+	if err != nil {
+		t.Fatalf("TableFromPostgres failed: %v", err)
+	}
+	store.Schema.CreateTable(table)
+
+	// Rows have no id; they are matched on order_id when resuming
+	rows := []storage.Row{
+		{"order_id": "1", "total": "9.99"},
+		{"order_id": "2", "total": "19.99"},
+		{"order_id": "3", "total": "5.00"},
+		{"order_id": "4", "total": "1.50"},
+		{"order_id": "5", "total": "2.25"},
+	}
+
+	// The source fails mid-way; completed batches are kept
+	stats, err := ImportRows(store, "orders", &sliceSource{rows: rows, failAfter: 3}, 2)
+	if err == nil {
+		t.Fatal("Expected read error")
+	}
+	if stats.Imported != 2 {
+		t.Errorf("Expected 2 rows imported before failure, got %d", stats.Imported)
+	}
+
+	// Re-running skips rows that are already present
+	stats, err = ImportRows(store, "orders", &sliceSource{rows: rows}, 2)
+	if err != nil {
+		t.Fatalf("ImportRows failed: %v", err)
+	}
+	if stats.Imported != 3 || stats.Skipped != 2 {
+		t.Errorf("Expected 3 imported and 2 skipped, got %+v", stats)
+	}
+
+	count, _ := store.Data.Count("orders")
+	if count != 5 {
+		t.Errorf("Expected 5 rows, got %d", count)
+	}
+}
+
+func TestImportRows_CompositePrimaryKey(t *testing.T) {
+	store := setupTestStore(t)
+
+	table, err := TableFromPostgres("memberships", []SourceColumn{
+		{Name: "org_id", DataType: "text", PrimaryKey: true},
+		{Name: "user_id", DataType: "text", PrimaryKey: true},
+		{Name: "role", DataType: "text", Nullable: true},
+	})
+	if err != nil {
+		t.Fatalf("TableFromPostgres failed: %v", err)
+	}
+	store.Schema.CreateTable(table)
+
+	// Key components repeat across rows; only the pair is unique
+	rows := []storage.Row{
+		{"org_id": "o1", "user_id": "u1", "role": "admin"},
+		{"org_id": "o1", "user_id": "u2"},
+		{"org_id": "o2", "user_id": "u1"},
+	}
+
+	stats, err := ImportRows(store, "memberships", &sliceSource{rows: rows}, 2)
+	if err != nil {
+		t.Fatalf("ImportRows failed: %v", err)
+	}
+	if stats.Imported != 3 {
+		t.Errorf("Expected 3 rows imported, got %+v", stats)
+	}
+
+	// A re-run matches rows on the whole key
+	stats, err = ImportRows(store, "memberships", &sliceSource{rows: rows}, 2)
+	if err != nil {
+		t.Fatalf("Re-running ImportRows failed: %v", err)
+	}
+	if stats.Imported != 0 || stats.Skipped != 3 {
+		t.Errorf("Expected 3 rows skipped, got %+v", stats)
+	}
+}
+
+func TestImportRows_MissingKey(t *testing.T) {
+	store := setupTestStore(t)
+
+	store.Schema.CreateTable(&storage.Table{
+		Name:    "events",
+		Columns: []storage.Column{{Name: "id", Type: "text"}, {Name: "name", Type: "text"}},
+	})
+
+	// A row without a key can't be recognised on a re-run, so nothing is
+	// written rather than duplicating it each time
+	rows := []storage.Row{{"id": "e1", "name": "a"}, {"name": "b"}}
+	if _, err := ImportRows(store, "events", &sliceSource{rows: rows}, 10); err == nil {
+		t.Error("Expected error for row without a key")
+	}
+
+	count, _ := store.Data.Count("events")
+	if count != 0 {
+		t.Errorf("Expected no rows, got %d", count)
+	}
+}
+
+func TestImportRows_UnknownTable(t *testing.T) {
+	store := setupTestStore(t)
+
+	if _, err := ImportRows(store, "missing", &sliceSource{}, 0); err == nil {
+		t.Error("Expected error for unknown table")
+	}
+}
@@ -0,0 +1,408 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/adrianmcphee/smarterbase/internal/executor"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/xwb1989/sqlparser"
+)
+
+// SQLSTATE codes for errors in extended query messages
+const (
+	codeProtocolViolation     = "08P01"
+	codeInvalidParameterValue = "22023"
+)
+
+// codedError is an error reported to the client with an SQLSTATE code
+type codedError struct {
+	code    string
+	message string
+}
+
+func (e *codedError) Error() string { return e.message }
+
+func protocolViolation(format string, args ...any) error {
+	return &codedError{code: codeProtocolViolation, message: fmt.Sprintf(format, args...)}
+}
+
+// Type OIDs understood when decoding binary parameters
+const (
+	oidBool    = 16
+	oidInt8    = 20
+	oidInt2    = 21
+	oidInt4    = 23
+	oidText    = 25
+	oidFloat4  = 700
+	oidFloat8  = 701
+	oidVarchar = 1043
+)
+
+// preparedStatement is a query prepared with Parse
+type preparedStatement struct {
+	query     string   // original query with $N placeholders
+	paramOIDs []uint32 // one per parameter; 0 lets the client choose
+	columns   []string // result columns, nil if the query returns no rows
+}
+
+// portal is a prepared statement bound to parameter values
+type portal struct {
+	stmt  *preparedStatement
+	query string // query with parameters substituted
+
+	// An Execute with a row limit runs the query once and suspends the
+	// portal; later Executes send the remaining rows
+	result *executor.Result
+	sent   int // rows of result already sent
+}
+
+// session holds per-connection state for the extended query protocol
+type session struct {
+	statements map[string]*preparedStatement
+	portals    map[string]*portal
+	buf        []byte // responses waiting for Sync or Flush
+	failed     bool   // after an error, messages are skipped until Sync
+}
+
+func newSession() *session {
+	return &session{
+		statements: make(map[string]*preparedStatement),
+		portals:    make(map[string]*portal),
+	}
+}
+
+// handleExtended processes Parse, Bind, Describe, Execute and Close.
+// Responses are buffered until the client sends Sync or Flush.
+func (s *Server) handleExtended(sess *session, msg pgproto3.FrontendMessage) {
+	if sess.failed {
+		return
+	}
+
+	var err error
+	switch m := msg.(type) {
+	case *pgproto3.Parse:
+		err = s.handleParse(sess, m)
+	case *pgproto3.Bind:
+		err = s.handleBind(sess, m)
+	case *pgproto3.Describe:
+		err = s.handleDescribe(sess, m)
+	case *pgproto3.Execute:
+		err = s.handleExecute(sess, m)
+	case *pgproto3.Close:
+		if m.ObjectType == 'S' {
+			delete(sess.statements, m.Name)
+		} else {
+			delete(sess.portals, m.Name)
+		}
+		sess.buf = appendEmptyMessage(sess.buf, '3') // CloseComplete
+	}
+
+	if err != nil {
+		var coded *codedError
+		if errors.As(err, &coded) {
+			sess.buf = appendErrorCode(sess.buf, coded.code, coded.message)
+		} else {
+			sess.buf = appendError(sess.buf, err.Error())
+		}
+		sess.failed = true
+	}
+}
+
+// handleSync ends an extended query cycle
+func (s *Server) handleSync(conn net.Conn, sess *session) {
+	sess.failed = false
+
+	// The unnamed portal only lives until the end of the transaction
+	delete(sess.portals, "")
+
+	sess.buf = append(sess.buf, 'Z')
+	sess.buf = appendInt32(sess.buf, 5)
+	sess.buf = append(sess.buf, 'I')
+	s.flush(conn, sess)
+}
+
+// flush writes any buffered responses to the client
+func (s *Server) flush(conn net.Conn, sess *session) {
+	if len(sess.buf) == 0 {
+		return
+	}
+	if _, err := conn.Write(sess.buf); err != nil {
+		log.Printf("write error: %v", err)
+	}
+	sess.buf = sess.buf[:0]
+}
+
+func (s *Server) handleParse(sess *session, m *pgproto3.Parse) error {
+	log.Printf("Parse: %s", m.Query)
+
+	if m.Name != "" {
+		if _, exists := sess.statements[m.Name]; exists {
+			return fmt.Errorf("prepared statement %q already exists", m.Name)
+		}
+	}
+
+	// The parser has no $N syntax, so describe the query with :vN instead
+	described, count := rewritePlaceholders(m.Query, func(n int) string {
+		return ":v" + strconv.Itoa(n)
+	})
+
+	columns, err := s.describe(described)
+	if err != nil {
+		return err
+	}
+
+	oids := make([]uint32, count)
+	copy(oids, m.ParameterOIDs)
+
+	sess.statements[m.Name] = &preparedStatement{
+		query:     m.Query,
+		paramOIDs: oids,
+		columns:   columns,
+	}
+	sess.buf = appendEmptyMessage(sess.buf, '1') // ParseComplete
+	return nil
+}
+
+func (s *Server) handleBind(sess *session, m *pgproto3.Bind) error {
+	stmt, ok := sess.statements[m.PreparedStatement]
+	if !ok {
+		return fmt.Errorf("prepared statement %q does not exist", m.PreparedStatement)
+	}
+
+	if len(m.Parameters) != len(stmt.paramOIDs) {
+		return fmt.Errorf("bind message supplies %d parameters, but prepared statement %q requires %d",
+			len(m.Parameters), m.PreparedStatement, len(stmt.paramOIDs))
+	}
+
+	// Format codes: none means all text, one applies to every value,
+	// otherwise there must be one per value
+	if n := len(m.ParameterFormatCodes); n > 1 && n != len(m.Parameters) {
+		return protocolViolation("bind message has %d parameter formats but %d parameters", n, len(m.Parameters))
+	}
+	if n := len(m.ResultFormatCodes); n > 1 && n != len(stmt.columns) {
+		return protocolViolation("bind message has %d result formats but query has %d columns", n, len(stmt.columns))
+	}
+	// Every column is described as text, whose binary and text forms are
+	// the same bytes, so either result format can be honoured
+	for _, codes := range [][]int16{m.ParameterFormatCodes, m.ResultFormatCodes} {
+		for _, code := range codes {
+			if code != 0 && code != 1 {
+				return &codedError{code: codeInvalidParameterValue, message: fmt.Sprintf("unsupported format code: %d", code)}
+			}
+		}
+	}
+
+	literals := make([]string, len(m.Parameters))
+	for i, param := range m.Parameters {
+		var format int16
+		switch len(m.ParameterFormatCodes) {
+		case 0:
+		case 1:
+			format = m.ParameterFormatCodes[0]
+		default:
+			format = m.ParameterFormatCodes[i]
+		}
+
+		literal, err := paramLiteral(param, format, stmt.paramOIDs[i])
+		if err != nil {
+			return fmt.Errorf("parameter $%d: %w", i+1, err)
+		}
+		literals[i] = literal
+	}
+
+	query, _ := rewritePlaceholders(stmt.query, func(n int) string {
+		return literals[n-1]
+	})
+
+	sess.portals[m.DestinationPortal] = &portal{stmt: stmt, query: query}
+	sess.buf = appendEmptyMessage(sess.buf, '2') // BindComplete
+	return nil
+}
+
+func (s *Server) handleDescribe(sess *session, m *pgproto3.Describe) error {
+	var stmt *preparedStatement
+	if m.ObjectType == 'S' {
+		var ok bool
+		stmt, ok = sess.statements[m.Name]
+		if !ok {
+			return fmt.Errorf("prepared statement %q does not exist", m.Name)
+		}
+		sess.buf = appendParameterDescription(sess.buf, stmt.paramOIDs)
+	} else {
+		p, ok := sess.portals[m.Name]
+		if !ok {
+			return fmt.Errorf("portal %q does not exist", m.Name)
+		}
+		stmt = p.stmt
+	}
+
+	if len(stmt.columns) == 0 {
+		sess.buf = appendEmptyMessage(sess.buf, 'n') // NoData
+		return nil
+	}
+
+	oids := make([]int32, len(stmt.columns))
+	for i := range oids {
+		oids[i] = oidText
+	}
+	sess.buf = appendRowDescription(sess.buf, stmt.columns, oids)
+	return nil
+}
+
+func (s *Server) handleExecute(sess *session, m *pgproto3.Execute) error {
+	p, ok := sess.portals[m.Portal]
+	if !ok {
+		return fmt.Errorf("portal %q does not exist", m.Portal)
+	}
+
+	if p.result == nil {
+		log.Printf("Execute: %s", p.query)
+
+		if strings.TrimSpace(p.query) == "" {
+			sess.buf = appendEmptyMessage(sess.buf, 'I') // EmptyQueryResponse
+			return nil
+		}
+
+		result, err := s.execute(p.query)
+		if err != nil {
+			return err
+		}
+		p.result = result
+	}
+
+	// The row description was sent by Describe, so only rows follow.
+	// MaxRows of 0 means no limit.
+	rows := p.result.Rows[p.sent:]
+	if m.MaxRows > 0 && uint64(len(rows)) > uint64(m.MaxRows) {
+		rows = rows[:m.MaxRows]
+	}
+	for _, row := range rows {
+		sess.buf = appendDataRow(sess.buf, row)
+	}
+	p.sent += len(rows)
+
+	if p.sent < len(p.result.Rows) {
+		sess.buf = appendEmptyMessage(sess.buf, 's') // PortalSuspended
+		return nil
+	}
+
+	// As in PostgreSQL, a SELECT's tag counts the rows sent by this Execute
+	tag := p.result.Message
+	if len(p.result.Columns) > 0 {
+		tag = fmt.Sprintf("SELECT %d", len(rows))
+	}
+	sess.buf = appendCommandComplete(sess.buf, tag)
+	return nil
+}
+
+// rewritePlaceholders replaces each $N placeholder outside quotes with
+// replace(N) and returns the new query and the highest N seen
+func rewritePlaceholders(query string, replace func(n int) string) (string, int) {
+	var sb strings.Builder
+	highest := 0
+	var quote byte
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+
+		if quote != 0 {
+			sb.WriteByte(c)
+			switch {
+			case c == '\\' && quote == '\'' && i+1 < len(query):
+				i++
+				sb.WriteByte(query[i])
+			case c == quote:
+				quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			sb.WriteByte(c)
+		case c == '$' && i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9':
+			j := i + 1
+			for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+				j++
+			}
+			n, err := strconv.Atoi(query[i+1 : j])
+			if err != nil || n == 0 {
+				sb.WriteString(query[i:j])
+			} else {
+				if n > highest {
+					highest = n
+				}
+				sb.WriteString(replace(n))
+			}
+			i = j - 1
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return sb.String(), highest
+}
+
+// paramLiteral renders a bound parameter as an SQL literal
+func paramLiteral(value []byte, format int16, oid uint32) (string, error) {
+	if value == nil {
+		return "NULL", nil
+	}
+
+	if format == 1 {
+		text, err := decodeBinaryParam(value, oid)
+		if err != nil {
+			return "", err
+		}
+		value = []byte(text)
+	}
+
+	return sqlparser.String(sqlparser.NewStrVal(value)), nil
+}
+
+// decodeBinaryParam converts a binary-format parameter to its text form
+func decodeBinaryParam(value []byte, oid uint32) (string, error) {
+	switch {
+	case oid == oidText || oid == oidVarchar:
+		return string(value), nil
+	case oid == oidBool && len(value) == 1:
+		return strconv.FormatBool(value[0] != 0), nil
+	case oid == oidInt2 && len(value) == 2:
+		return strconv.FormatInt(int64(int16(binary.BigEndian.Uint16(value))), 10), nil
+	case oid == oidInt4 && len(value) == 4:
+		return strconv.FormatInt(int64(int32(binary.BigEndian.Uint32(value))), 10), nil
+	case oid == oidInt8 && len(value) == 8:
+		return strconv.FormatInt(int64(binary.BigEndian.Uint64(value)), 10), nil
+	case oid == oidFloat4 && len(value) == 4:
+		f := math.Float32frombits(binary.BigEndian.Uint32(value))
+		return strconv.FormatFloat(float64(f), 'f', -1, 32), nil
+	case oid == oidFloat8 && len(value) == 8:
+		f := math.Float64frombits(binary.BigEndian.Uint64(value))
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("binary format not supported for type OID %d", oid)
+}
+
+func appendEmptyMessage(buf []byte, msgType byte) []byte {
+	buf = append(buf, msgType)
+	return appendInt32(buf, 4)
+}
+
+func appendParameterDescription(buf []byte, oids []uint32) []byte {
+	// 't' + int32(len) + int16(count) + int32 oid per parameter
+	buf = append(buf, 't')
+	buf = appendInt32(buf, int32(4+2+4*len(oids)))
+	buf = appendInt16(buf, int16(len(oids)))
+	for _, oid := range oids {
+		buf = appendInt32(buf, int32(oid))
+	}
+	return buf
+}
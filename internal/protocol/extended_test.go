@@ -0,0 +1,196 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+)
+
+func TestRewritePlaceholders(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+		count    int
+	}{
+		{"SELECT * FROM users WHERE id = $1", "SELECT * FROM users WHERE id = :v1", 1},
+		{"INSERT INTO t (a, b) VALUES ($2, $1)", "INSERT INTO t (a, b) VALUES (:v2, :v1)", 2},
+		{"SELECT * FROM t WHERE a = '$1' AND b = $1", "SELECT * FROM t WHERE a = '$1' AND b = :v1", 1},
+		{`SELECT * FROM t WHERE a = 'it\'s $1' AND b = $1`, `SELECT * FROM t WHERE a = 'it\'s $1' AND b = :v1`, 1},
+		{"SELECT * FROM t WHERE a = 'it''s $1' AND b = $1", "SELECT * FROM t WHERE a = 'it''s $1' AND b = :v1", 1},
+		{`SELECT "$1" FROM t WHERE a = $10`, `SELECT "$1" FROM t WHERE a = :v10`, 10},
+		{"SELECT * FROM t WHERE price = $", "SELECT * FROM t WHERE price = $", 0},
+	}
+
+	for _, tt := range tests {
+		got, count := rewritePlaceholders(tt.query, func(n int) string {
+			return ":v" + strconv.Itoa(n)
+		})
+		if got != tt.expected || count != tt.count {
+			t.Errorf("rewritePlaceholders(%q) = %q, %d; expected %q, %d",
+				tt.query, got, count, tt.expected, tt.count)
+		}
+	}
+}
+
+func TestParamLiteral(t *testing.T) {
+	tests := []struct {
+		value    []byte
+		format   int16
+		oid      uint32
+		expected string
+	}{
+		{nil, 0, 0, "NULL"},
+		{[]byte("O'Brien"), 0, 0, `'O\'Brien'`},
+		{[]byte{0, 0, 0, 42}, 1, oidInt4, "'42'"},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe}, 1, oidInt8, "'-2'"},
+		{[]byte{1}, 1, oidBool, "'true'"},
+	}
+
+	for _, tt := range tests {
+		got, err := paramLiteral(tt.value, tt.format, tt.oid)
+		if err != nil {
+			t.Errorf("paramLiteral(%v) failed: %v", tt.value, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("paramLiteral(%v) = %s, expected %s", tt.value, got, tt.expected)
+		}
+	}
+
+	if _, err := paramLiteral([]byte{1, 2}, 1, 0); err == nil {
+		t.Error("Expected error for binary parameter of unknown type")
+	}
+}
+
+// setupTestSession returns a server with a table of three rows and a session
+// with the statement "SELECT id FROM items WHERE id != $1" prepared
+func setupTestSession(t *testing.T) (*Server, *session) {
+	t.Helper()
+
+	s, err := NewServer(0, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	for _, sql := range []string{
+		"CREATE TABLE items (id TEXT PRIMARY KEY)",
+		"INSERT INTO items (id) VALUES ('a'), ('b'), ('c')",
+	} {
+		if _, err := s.execute(sql); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+
+	sess := newSession()
+	s.handleExtended(sess, &pgproto3.Parse{Query: "SELECT id FROM items WHERE id != $1"})
+	if sess.failed {
+		t.Fatalf("Parse failed: %v", receiveAll(t, sess))
+	}
+	sess.buf = sess.buf[:0]
+	return s, sess
+}
+
+// receiveAll decodes and clears the responses buffered in sess
+func receiveAll(t *testing.T, sess *session) []pgproto3.BackendMessage {
+	t.Helper()
+
+	frontend := pgproto3.NewFrontend(pgproto3.NewChunkReader(bytes.NewReader(sess.buf)), io.Discard)
+	var msgs []pgproto3.BackendMessage
+	for {
+		msg, err := frontend.Receive()
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+
+		// Messages are reused by the next Receive, so keep what the tests use
+		switch m := msg.(type) {
+		case *pgproto3.ErrorResponse:
+			msgs = append(msgs, &pgproto3.ErrorResponse{Code: m.Code, Message: m.Message})
+		case *pgproto3.CommandComplete:
+			msgs = append(msgs, &pgproto3.CommandComplete{CommandTag: append([]byte(nil), m.CommandTag...)})
+		default:
+			msgs = append(msgs, msg)
+		}
+	}
+	sess.buf = sess.buf[:0]
+	return msgs
+}
+
+func TestBind_MalformedFormatCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		bind *pgproto3.Bind
+		code string
+	}{
+		{
+			"parameter formats",
+			&pgproto3.Bind{ParameterFormatCodes: []int16{0, 0}, Parameters: [][]byte{[]byte("a")}},
+			"08P01",
+		},
+		{
+			"result formats",
+			&pgproto3.Bind{Parameters: [][]byte{[]byte("a")}, ResultFormatCodes: []int16{0, 0, 0}},
+			"08P01",
+		},
+		{
+			"format code",
+			&pgproto3.Bind{ParameterFormatCodes: []int16{7}, Parameters: [][]byte{[]byte("a")}},
+			"22023",
+		},
+	}
+
+	for _, tt := range tests {
+		s, sess := setupTestSession(t)
+
+		// Must be reported to the client rather than panic
+		s.handleExtended(sess, tt.bind)
+		msgs := receiveAll(t, sess)
+		if len(msgs) != 1 {
+			t.Fatalf("%s: expected one response, got %v", tt.name, msgs)
+		}
+		errResp, ok := msgs[0].(*pgproto3.ErrorResponse)
+		if !ok || errResp.Code != tt.code {
+			t.Errorf("%s: expected error %s, got %#v", tt.name, tt.code, msgs[0])
+		}
+		if !sess.failed {
+			t.Errorf("%s: expected session to skip messages until Sync", tt.name)
+		}
+	}
+}
+
+func TestExecute_MaxRows(t *testing.T) {
+	s, sess := setupTestSession(t)
+
+	s.handleExtended(sess, &pgproto3.Bind{Parameters: [][]byte{[]byte("z")}})
+	receiveAll(t, sess)
+
+	// Two rows, then suspended; the last row and the tag on the next call
+	var got []string
+	for i := 0; i < 2; i++ {
+		s.handleExtended(sess, &pgproto3.Execute{MaxRows: 2})
+		for _, msg := range receiveAll(t, sess) {
+			switch m := msg.(type) {
+			case *pgproto3.DataRow:
+				got = append(got, "row")
+			case *pgproto3.PortalSuspended:
+				got = append(got, "suspended")
+			case *pgproto3.CommandComplete:
+				got = append(got, string(m.CommandTag))
+			default:
+				t.Fatalf("Unexpected message %#v", msg)
+			}
+		}
+	}
+
+	expected := "row,row,suspended,row,SELECT 1"
+	if g := strings.Join(got, ","); g != expected {
+		t.Errorf("Expected %s, got %s", expected, g)
+	}
+}
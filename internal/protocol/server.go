@@ -2,6 +2,8 @@
 package protocol
 
 import (
+	"crypto/subtle"
+	"crypto/tls"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -15,21 +17,71 @@ import (
 
 // Server handles PostgreSQL wire protocol connections
 type Server struct {
-	listener net.Listener
-	port     int
-	executor *executor.Executor
+	listener  net.Listener
+	port      int
+	executor  *executor.Executor
+	opts      Options
+	tlsConfig *tls.Config
+}
+
+// Options configures authentication and encryption for a server
+type Options struct {
+	// AuthUser and AuthPassword, when set, require clients to log in with
+	// this user and password. The password is sent in cleartext, so TLS
+	// must be configured too.
+	AuthUser     string
+	AuthPassword string
+
+	// TLSCertFile and TLSKeyFile, when set, enable TLS. Clients that don't
+	// request TLS are then rejected, so passwords never cross the wire in
+	// the clear.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// Store tunes lock striping and fsync for the underlying store
+	Store storage.StoreOptions
 }
 
 // NewServer creates a new protocol server with storage
 func NewServer(port int, dataDir string) (*Server, error) {
-	store, err := storage.NewStore(dataDir)
+	return NewServerWithOptions(port, dataDir, Options{})
+}
+
+// NewServerWithOptions creates a protocol server with authentication and
+// TLS configured by opts
+func NewServerWithOptions(port int, dataDir string, opts Options) (*Server, error) {
+	if (opts.AuthUser == "") != (opts.AuthPassword == "") {
+		return nil, fmt.Errorf("auth user and password must be set together")
+	}
+	if (opts.TLSCertFile == "") != (opts.TLSKeyFile == "") {
+		return nil, fmt.Errorf("TLS cert and key must be set together")
+	}
+	if opts.AuthUser != "" && opts.TLSCertFile == "" {
+		return nil, fmt.Errorf("password authentication requires TLS, so passwords aren't sent in the clear")
+	}
+
+	var tlsConfig *tls.Config
+	if opts.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.TLSCertFile, opts.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load TLS certificate: %w", err)
+		}
+		tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+
+	store, err := storage.NewStoreWithOptions(dataDir, opts.Store)
 	if err != nil {
 		return nil, fmt.Errorf("create store: %w", err)
 	}
 
 	return &Server{
-		port:     port,
-		executor: executor.NewExecutor(store),
+		port:      port,
+		executor:  executor.NewExecutor(store),
+		opts:      opts,
+		tlsConfig: tlsConfig,
 	}, nil
 }
 
@@ -60,15 +112,19 @@ func (s *Server) handleConnection(conn net.Conn) {
 
 	log.Printf("New connection from %s", conn.RemoteAddr())
 
-	// Handle SSL negotiation first (before creating backend)
-	if err := s.handleSSLRequest(conn); err != nil {
-		log.Printf("SSL handling error: %v", err)
+	// Handle SSL negotiation and startup first (before creating backend)
+	conn, err := s.handleSSLRequest(conn, false)
+	if err != nil {
+		log.Printf("Startup error: %v", err)
 		return
 	}
+	defer conn.Close()
 
 	// Create backend (server-side) protocol handler
 	backend := pgproto3.NewBackend(pgproto3.NewChunkReader(conn), conn)
 
+	sess := newSession()
+
 	// Main message loop
 	for {
 		msg, err := backend.Receive()
@@ -85,6 +141,16 @@ func (s *Server) handleConnection(conn net.Conn) {
 		case *pgproto3.Query:
 			s.handleQuery(conn, m.String)
 
+		case *pgproto3.Parse, *pgproto3.Bind, *pgproto3.Describe,
+			*pgproto3.Execute, *pgproto3.Close:
+			s.handleExtended(sess, m)
+
+		case *pgproto3.Sync:
+			s.handleSync(conn, sess)
+
+		case *pgproto3.Flush:
+			s.flush(conn, sess)
+
 		case *pgproto3.Terminate:
 			log.Printf("Client terminated connection")
 			return
@@ -95,41 +161,62 @@ func (s *Server) handleConnection(conn net.Conn) {
 	}
 }
 
-// handleSSLRequest checks for and declines SSL requests
-func (s *Server) handleSSLRequest(conn net.Conn) error {
+// handleSSLRequest checks for SSL requests, upgrading to TLS when configured
+// and declining otherwise. It returns the connection to use from then on.
+func (s *Server) handleSSLRequest(conn net.Conn, secure bool) (net.Conn, error) {
 	// Read the first message length
 	header := make([]byte, 4)
 	_, err := io.ReadFull(conn, header)
 	if err != nil {
-		return fmt.Errorf("read header: %w", err)
+		return nil, fmt.Errorf("read header: %w", err)
 	}
 
 	msgLen := int(binary.BigEndian.Uint32(header))
+	if msgLen < 8 || msgLen > 10000 {
+		return nil, fmt.Errorf("invalid startup message length %d", msgLen)
+	}
 
 	// Read the rest of the message
 	msg := make([]byte, msgLen-4)
 	_, err = io.ReadFull(conn, msg)
 	if err != nil {
-		return fmt.Errorf("read message: %w", err)
+		return nil, fmt.Errorf("read message: %w", err)
 	}
 
 	// Check if it's an SSL request (magic number 80877103)
 	if msgLen == 8 {
 		code := binary.BigEndian.Uint32(msg)
 		if code == 80877103 {
-			// SSL request - decline with 'N'
-			log.Printf("SSL request received, declining")
-			_, err = conn.Write([]byte{'N'})
-			if err != nil {
-				return fmt.Errorf("write SSL response: %w", err)
+			if s.tlsConfig == nil || secure {
+				// SSL request - decline with 'N'
+				log.Printf("SSL request received, declining")
+				if _, err := conn.Write([]byte{'N'}); err != nil {
+					return nil, fmt.Errorf("write SSL response: %w", err)
+				}
+				// Client will send regular startup next, recurse
+				return s.handleSSLRequest(conn, secure)
+			}
+
+			if _, err := conn.Write([]byte{'S'}); err != nil {
+				return nil, fmt.Errorf("write SSL response: %w", err)
 			}
-			// Client will send regular startup next, recurse
-			return s.handleSSLRequest(conn)
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return nil, fmt.Errorf("TLS handshake: %w", err)
+			}
+			// Startup follows over the encrypted connection
+			return s.handleSSLRequest(tlsConn, true)
 		}
 	}
 
+	if s.tlsConfig != nil && !secure {
+		// The connection is closed either way, so a write error adds nothing
+		_, _ = conn.Write(appendFatal(nil, "28000", "SSL connection is required"))
+		return nil, fmt.Errorf("rejected connection without TLS")
+	}
+
 	// Not SSL - it's a startup message
-	return s.processStartupMessage(conn, msg)
+	return conn, s.processStartupMessage(conn, msg)
 }
 
 // processStartupMessage handles the startup after we've read it during SSL check
@@ -178,6 +265,12 @@ func (s *Server) processStartupMessage(conn net.Conn, msg []byte) error {
 
 	log.Printf("Startup: database=%s user=%s", params["database"], params["user"])
 
+	if s.opts.AuthUser != "" {
+		if err := s.authenticate(conn, params["user"]); err != nil {
+			return err
+		}
+	}
+
 	// Build response manually
 	buf := make([]byte, 0, 256)
 
@@ -209,39 +302,65 @@ func (s *Server) processStartupMessage(conn net.Conn, msg []byte) error {
 	return err
 }
 
+// authenticate asks the client for a cleartext password and checks it and
+// the startup user against the configured credentials
+func (s *Server) authenticate(conn net.Conn, user string) error {
+	// AuthenticationCleartextPassword: 'R' + int32(8) + int32(3)
+	buf := []byte{'R'}
+	buf = appendInt32(buf, 8)
+	buf = appendInt32(buf, 3)
+	if _, err := conn.Write(buf); err != nil {
+		return fmt.Errorf("write auth request: %w", err)
+	}
+
+	// PasswordMessage: 'p' + int32(len) + password\0
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("read password: %w", err)
+	}
+	msgLen := int(binary.BigEndian.Uint32(header[1:]))
+	if header[0] != 'p' || msgLen < 5 || msgLen > 10000 {
+		return fmt.Errorf("expected password message")
+	}
+	body := make([]byte, msgLen-4)
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return fmt.Errorf("read password: %w", err)
+	}
+	password := string(body[:len(body)-1])
+
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(s.opts.AuthUser)) == 1
+	passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(s.opts.AuthPassword)) == 1
+	if !userOK || !passwordOK {
+		_, _ = conn.Write(appendFatal(nil, "28P01", fmt.Sprintf("password authentication failed for user %q", user)))
+		return fmt.Errorf("authentication failed for user %q", user)
+	}
+
+	return nil
+}
+
 // handleQuery processes a simple query
 func (s *Server) handleQuery(conn net.Conn, query string) {
 	log.Printf("Query: %s", query)
 
 	buf := make([]byte, 0, 512)
 
-	// Handle special queries that clients expect
-	switch {
-	case query == "SELECT version()" || query == "SELECT version();":
-		buf = appendRowDescription(buf, []string{"version"}, []int32{25})
-		buf = appendDataRow(buf, []string{"SmarterBase 1.0.0 - PostgreSQL compatible file store"})
-		buf = appendCommandComplete(buf, "SELECT 1")
-
-	default:
-		// Execute via SQL executor
-		result, err := s.executor.Execute(query)
-		if err != nil {
-			buf = appendError(buf, err.Error())
-		} else {
-			// Send result based on type
-			if len(result.Columns) > 0 {
-				// SELECT query with results
-				oids := make([]int32, len(result.Columns))
-				for i := range oids {
-					oids[i] = 25 // text OID
-				}
-				buf = appendRowDescription(buf, result.Columns, oids)
-				for _, row := range result.Rows {
-					buf = appendDataRow(buf, row)
-				}
+	result, err := s.execute(query)
+	if err != nil {
+		buf = appendError(buf, err.Error())
+	} else {
+		// Send result based on type
+		if len(result.Columns) > 0 {
+			// SELECT query with results
+			oids := make([]int32, len(result.Columns))
+			for i := range oids {
+				oids[i] = 25 // text OID
+			}
+			buf = appendRowDescription(buf, result.Columns, oids)
+			for _, row := range result.Rows {
+				buf = appendDataRow(buf, row)
 			}
-			buf = appendCommandComplete(buf, result.Message)
 		}
+		buf = appendCommandComplete(buf, result.Message)
 	}
 
 	// ReadyForQuery
@@ -254,6 +373,32 @@ func (s *Server) handleQuery(conn net.Conn, query string) {
 	}
 }
 
+// isVersionQuery reports whether query is SELECT version(), which clients
+// send on connect and the executor can't answer
+func isVersionQuery(query string) bool {
+	return query == "SELECT version()" || query == "SELECT version();"
+}
+
+// execute runs a query, answering queries clients expect directly
+func (s *Server) execute(query string) (*executor.Result, error) {
+	if isVersionQuery(query) {
+		return &executor.Result{
+			Columns: []string{"version"},
+			Rows:    [][]string{{"SmarterBase 1.0.0 - PostgreSQL compatible file store"}},
+			Message: "SELECT 1",
+		}, nil
+	}
+	return s.executor.Execute(query)
+}
+
+// describe returns the result columns of a query without running it
+func (s *Server) describe(query string) ([]string, error) {
+	if isVersionQuery(query) {
+		return []string{"version"}, nil
+	}
+	return s.executor.Describe(query)
+}
+
 // Helper functions to build protocol messages
 
 func appendInt32(buf []byte, v int32) []byte {
@@ -339,6 +484,30 @@ func appendNotice(buf []byte, message string) []byte {
 	return buf
 }
 
+func appendFatal(buf []byte, code, message string) []byte {
+	return appendErrorResponse(buf, "FATAL", code, message)
+}
+
+// appendErrorCode appends an ERROR with an SQLSTATE code
+func appendErrorCode(buf []byte, code, message string) []byte {
+	return appendErrorResponse(buf, "ERROR", code, message)
+}
+
+func appendErrorResponse(buf []byte, severity, code, message string) []byte {
+	// 'E' + length + 'S' + severity\0 + 'C' + code\0 + 'M' + message\0 + \0
+	msgLen := 4 + 1 + len(severity) + 1 + 1 + len(code) + 1 + 1 + len(message) + 1 + 1
+	buf = append(buf, 'E')
+	buf = appendInt32(buf, int32(msgLen))
+	buf = append(buf, 'S')
+	buf = appendString(buf, severity)
+	buf = append(buf, 'C')
+	buf = appendString(buf, code)
+	buf = append(buf, 'M')
+	buf = appendString(buf, message)
+	buf = append(buf, 0) // terminator
+	return buf
+}
+
 func appendError(buf []byte, message string) []byte {
 	// 'E' + length + 'S' + "ERROR\0" + 'M' + message\0 + \0
 	severity := "ERROR"
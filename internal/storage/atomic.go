@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes a file by writing a temp file in the same directory
+// and renaming it into place, so readers see either the old or the new
+// contents and never a partial write. With fsync, the temp file and then the
+// directory entry are flushed to disk, so the rename survives a power loss.
+func writeFileAtomic(path string, fsync bool, write func(w io.Writer) error) error {
+	tempPath := path + ".tmp"
+
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+
+	fail := func(err error) error {
+		file.Close()
+		os.Remove(tempPath)
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	if err := write(writer); err != nil {
+		return fail(err)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fail(fmt.Errorf("flush: %w", err))
+	}
+
+	if fsync {
+		if err := file.Sync(); err != nil {
+			return fail(fmt.Errorf("sync: %w", err))
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("close: %w", err)
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("rename: %w", err)
+	}
+
+	if fsync {
+		if err := syncDir(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("sync dir: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// syncDir flushes a directory's entries, making a rename within it durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.jsonl")
+
+	for _, fsync := range []bool{false, true} {
+		err := writeFileAtomic(path, fsync, func(w io.Writer) error {
+			_, err := io.WriteString(w, "{\"id\":\"u1\"}\n")
+			return err
+		})
+		if err != nil {
+			t.Fatalf("writeFileAtomic(fsync=%v) failed: %v", fsync, err)
+		}
+	}
+
+	// A failed write leaves the previous contents and no temp file behind
+	err := writeFileAtomic(path, true, func(w io.Writer) error {
+		io.WriteString(w, "{\"id\":")
+		return errors.New("killed mid-write")
+	})
+	if err == nil {
+		t.Fatal("Expected write error")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if string(data) != "{\"id\":\"u1\"}\n" {
+		t.Errorf("Expected previous contents, got %q", data)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected temp file to be removed, got %v", err)
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,17 +23,37 @@ type Row map[string]any
 type DataStore struct {
 	dataDir string
 	schema  *SchemaStore
-	mu      sync.RWMutex
+	locks   []sync.RWMutex // striped by table name
+	fsync   bool
 }
 
 // NewDataStore creates a new data store
 func NewDataStore(dataDir string, schema *SchemaStore) *DataStore {
+	return NewDataStoreWithOptions(dataDir, schema, StoreOptions{})
+}
+
+// NewDataStoreWithOptions creates a data store tuned by opts
+func NewDataStoreWithOptions(dataDir string, schema *SchemaStore, opts StoreOptions) *DataStore {
+	stripes := opts.LockStripes
+	if stripes <= 0 {
+		stripes = DefaultLockStripes
+	}
+
 	return &DataStore{
 		dataDir: dataDir,
 		schema:  schema,
+		locks:   make([]sync.RWMutex, stripes),
+		fsync:   opts.Fsync,
 	}
 }
 
+// lockFor returns the lock guarding a table's file
+func (d *DataStore) lockFor(tableName string) *sync.RWMutex {
+	h := fnv.New32a()
+	h.Write([]byte(tableName))
+	return &d.locks[h.Sum32()%uint32(len(d.locks))]
+}
+
 // tablePath returns the path to a table's JSONL file
 func (d *DataStore) tablePath(tableName string) string {
 	return filepath.Join(d.dataDir, tableName+".jsonl")
@@ -65,17 +89,32 @@ func GenerateUUIDv7() string {
 
 // readAllRows reads all rows from a table's JSONL file
 func (d *DataStore) readAllRows(tableName string) ([]Row, error) {
+	var rows []Row
+	err := d.eachRow(tableName, func(row Row) error {
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if rows == nil {
+		rows = []Row{}
+	}
+	return rows, nil
+}
+
+// eachRow decodes a table's JSONL file line by line, calling fn for each row
+func (d *DataStore) eachRow(tableName string, fn func(Row) error) error {
 	path := d.tablePath(tableName)
 	file, err := os.Open(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return []Row{}, nil
+			return nil
 		}
-		return nil, err
+		return err
 	}
 	defer file.Close()
 
-	var rows []Row
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -86,123 +125,121 @@ func (d *DataStore) readAllRows(tableName string) ([]Row, error) {
 		if err := json.Unmarshal([]byte(line), &row); err != nil {
 			continue // Skip invalid lines
 		}
-		rows = append(rows, row)
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, err
+		if err := fn(row); err != nil {
+			return err
+		}
 	}
 
-	return rows, nil
+	return scanner.Err()
 }
 
 // writeAllRows writes all rows to a table's JSONL file atomically
 func (d *DataStore) writeAllRows(tableName string, rows []Row) error {
-	path := d.tablePath(tableName)
-	tempPath := path + ".tmp"
-
-	file, err := os.Create(tempPath)
-	if err != nil {
-		return fmt.Errorf("create temp file: %w", err)
-	}
-
-	writer := bufio.NewWriter(file)
-	for _, row := range rows {
-		data, err := json.Marshal(row)
-		if err != nil {
-			file.Close()
-			os.Remove(tempPath)
-			return fmt.Errorf("marshal row: %w", err)
-		}
-		if _, err := writer.Write(data); err != nil {
-			file.Close()
-			os.Remove(tempPath)
-			return fmt.Errorf("write row: %w", err)
-		}
-		if _, err := writer.WriteString("\n"); err != nil {
-			file.Close()
-			os.Remove(tempPath)
-			return fmt.Errorf("write newline: %w", err)
+	return writeFileAtomic(d.tablePath(tableName), d.fsync, func(w io.Writer) error {
+		for _, row := range rows {
+			data, err := json.Marshal(row)
+			if err != nil {
+				return fmt.Errorf("marshal row: %w", err)
+			}
+			if _, err := w.Write(data); err != nil {
+				return fmt.Errorf("write row: %w", err)
+			}
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return fmt.Errorf("write newline: %w", err)
+			}
 		}
-	}
-
-	if err := writer.Flush(); err != nil {
-		file.Close()
-		os.Remove(tempPath)
-		return fmt.Errorf("flush: %w", err)
-	}
-
-	if err := file.Close(); err != nil {
-		os.Remove(tempPath)
-		return fmt.Errorf("close: %w", err)
-	}
-
-	if err := os.Rename(tempPath, path); err != nil {
-		os.Remove(tempPath)
-		return fmt.Errorf("rename: %w", err)
-	}
-
-	return nil
+		return nil
+	})
 }
 
 // Insert inserts a new row into a table
 func (d *DataStore) Insert(tableName string, row Row) (string, error) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	ids, err := d.InsertBatch(tableName, []Row{row})
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// InsertBatch inserts multiple rows with a single rewrite of the table file.
+// Either every row is inserted or, on the first invalid row, none are.
+// Returns the IDs of the inserted rows in order.
+func (d *DataStore) InsertBatch(tableName string, newRows []Row) ([]string, error) {
+	mu := d.lockFor(tableName)
+	mu.Lock()
+	defer mu.Unlock()
 
 	// Verify table exists
 	table, err := d.schema.GetTable(tableName)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Generate ID if not provided
-	id, ok := row["id"].(string)
-	if !ok || id == "" {
-		id = GenerateUUIDv7()
-		row["id"] = id
+	if len(newRows) == 0 {
+		return []string{}, nil
 	}
 
-	// Validate columns exist in schema
 	columnMap := make(map[string]Column)
 	for _, col := range table.Columns {
 		columnMap[col.Name] = col
 	}
 
-	for colName := range row {
-		if _, exists := columnMap[colName]; !exists {
-			return "", fmt.Errorf("column %s does not exist in table %s", colName, tableName)
-		}
-	}
-
 	// Read existing rows
 	rows, err := d.readAllRows(tableName)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Check for duplicate ID
-	for _, existing := range rows {
-		if existing["id"] == id {
-			return "", fmt.Errorf("row with id %s already exists in table %s", id, tableName)
+	index := newUniqueIndex(table, rows, nil)
+
+	ids := make([]string, 0, len(newRows))
+	for _, row := range newRows {
+		// Generate ID if not provided
+		id, ok := row["id"].(string)
+		if !ok || id == "" {
+			id = GenerateUUIDv7()
+			row["id"] = id
+		}
+
+		// Validate columns exist in schema
+		for colName := range row {
+			if _, exists := columnMap[colName]; !exists {
+				return nil, fmt.Errorf("column %s does not exist in table %s", colName, tableName)
+			}
+		}
+
+		if err := applyDefaults(table, row); err != nil {
+			return nil, err
+		}
+
+		if err := checkNotNull(table, row, true); err != nil {
+			return nil, err
 		}
-	}
 
-	// Append new row
-	rows = append(rows, row)
+		// Check the ID and unique constraints, including earlier rows in
+		// this batch
+		if err := index.add(row); err != nil {
+			return nil, err
+		}
+
+		// Append new row
+		rows = append(rows, row)
+		ids = append(ids, id)
+	}
 
 	// Write all rows
 	if err := d.writeAllRows(tableName, rows); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return id, nil
+	return ids, nil
 }
 
 // Get retrieves a row by ID
 func (d *DataStore) Get(tableName, id string) (Row, error) {
-	d.mu.RLock()
-	defer d.mu.RUnlock()
+	mu := d.lockFor(tableName)
+	mu.RLock()
+	defer mu.RUnlock()
 
 	if !d.schema.TableExists(tableName) {
 		return nil, fmt.Errorf("table %s does not exist", tableName)
@@ -224,12 +261,21 @@ func (d *DataStore) Get(tableName, id string) (Row, error) {
 
 // Update updates an existing row
 func (d *DataStore) Update(tableName, id string, updates Row) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	_, err := d.UpdateBatch(tableName, []string{id}, updates)
+	return err
+}
+
+// UpdateBatch applies the same updates to several rows with a single rewrite
+// of the table file. It is all-or-nothing: if any row is missing or would
+// violate a constraint, nothing is written. Returns the number of rows updated.
+func (d *DataStore) UpdateBatch(tableName string, ids []string, updates Row) (int, error) {
+	mu := d.lockFor(tableName)
+	mu.Lock()
+	defer mu.Unlock()
 
 	table, err := d.schema.GetTable(tableName)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Validate update columns exist in schema
@@ -243,41 +289,247 @@ func (d *DataStore) Update(tableName, id string, updates Row) error {
 			continue
 		}
 		if _, exists := columnMap[colName]; !exists {
-			return fmt.Errorf("column %s does not exist in table %s", colName, tableName)
+			return 0, fmt.Errorf("column %s does not exist in table %s", colName, tableName)
 		}
 	}
 
+	if err := checkNotNull(table, updates, false); err != nil {
+		return 0, err
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
 	// Read all rows
 	rows, err := d.readAllRows(tableName)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	// Find and update the row
-	found := false
+	positions := make(map[string]int, len(rows))
 	for i, row := range rows {
-		if row["id"] == id {
-			for k, v := range updates {
-				if k != "id" {
-					rows[i][k] = v
-				}
+		if id, ok := row["id"].(string); ok {
+			positions[id] = i
+		}
+	}
+
+	// Apply every update first, so constraints are checked against the
+	// table as it will be written
+	for _, id := range ids {
+		target, ok := positions[id]
+		if !ok {
+			return 0, fmt.Errorf("row %s not found in table %s", id, tableName)
+		}
+
+		merged := make(Row, len(rows[target])+len(updates))
+		for k, v := range rows[target] {
+			merged[k] = v
+		}
+		for k, v := range updates {
+			if k != "id" {
+				merged[k] = v
 			}
-			found = true
+		}
+		rows[target] = merged
+	}
+
+	// Check unique constraints against the rows left alone, then against
+	// each other
+	updated := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		updated[id] = true
+	}
+	index := newUniqueIndex(table, rows, updated)
+	for _, id := range ids {
+		if !updated[id] {
+			continue // listed more than once
+		}
+		delete(updated, id)
+		if err := index.add(rows[positions[id]]); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := d.writeAllRows(tableName, rows); err != nil {
+		return 0, err
+	}
+
+	return len(ids), nil
+}
+
+// applyDefaults fills columns missing from row with their DEFAULT. Only
+// literal defaults are supported; omitting a column whose default is an
+// expression, such as now(), is an error rather than a silent NULL.
+func applyDefaults(table *Table, row Row) error {
+	for _, col := range table.Columns {
+		if col.Default == "" {
+			continue
+		}
+		if _, ok := row[col.Name]; ok {
+			continue
+		}
+
+		val, err := literalDefault(col.Default)
+		if err != nil {
+			return fmt.Errorf("column %s of table %s: %w", col.Name, table.Name, err)
+		}
+		row[col.Name] = val
+	}
+	return nil
+}
+
+// literalDefault evaluates a DEFAULT clause holding a quoted string, number,
+// boolean or NULL. A trailing PostgreSQL cast such as ::text is ignored.
+func literalDefault(def string) (any, error) {
+	lit := strings.TrimSpace(def)
+	if i := strings.LastIndex(lit, "::"); i > 0 && !strings.ContainsAny(lit[i:], "'") {
+		lit = strings.TrimSpace(lit[:i])
+	}
+
+	switch {
+	case strings.EqualFold(lit, "null"):
+		return nil, nil
+	case strings.EqualFold(lit, "true"), strings.EqualFold(lit, "false"):
+		return strings.ToLower(lit), nil
+	case len(lit) >= 2 && lit[0] == '\'' && lit[len(lit)-1] == '\'':
+		inner := lit[1 : len(lit)-1]
+		if strings.Contains(strings.ReplaceAll(inner, "''", ""), "'") {
 			break
 		}
+		return strings.ReplaceAll(inner, "''", "'"), nil
+	default:
+		if _, err := strconv.ParseFloat(lit, 64); err == nil {
+			return lit, nil
+		}
 	}
 
-	if !found {
-		return fmt.Errorf("row %s not found in table %s", id, tableName)
+	return nil, fmt.Errorf("default %s is not a literal and cannot be applied", def)
+}
+
+// checkNotNull returns an error if row leaves a NOT NULL or primary key column
+// NULL. With requireAll, a missing column counts as NULL (as for an INSERT);
+// otherwise only columns present in row are checked (as for an UPDATE).
+func checkNotNull(table *Table, row Row, requireAll bool) error {
+	for _, col := range table.Columns {
+		if !col.NotNull && !col.PrimaryKey {
+			continue
+		}
+
+		val, ok := row[col.Name]
+		if !ok && !requireAll {
+			continue
+		}
+		if val == nil {
+			return fmt.Errorf("null value in column %s of table %s violates not-null constraint", col.Name, table.Name)
+		}
 	}
 
-	return d.writeAllRows(tableName, rows)
+	return nil
+}
+
+// uniqueIndex holds the IDs and unique key values of a table's rows, so a
+// batch of new or changed rows can be checked without rescanning the table
+// for each one. NULL values never conflict.
+type uniqueIndex struct {
+	table       *Table
+	constraints [][]string // the primary key, then single- and multi-column unique constraints
+	ids         map[string]bool
+	keys        []map[string]bool // one set per constraint
+}
+
+// newUniqueIndex indexes rows, leaving out those whose ID is in skip
+func newUniqueIndex(table *Table, rows []Row, skip map[string]bool) *uniqueIndex {
+	var constraints [][]string
+	if pk := table.PrimaryKey(); len(pk) > 0 {
+		constraints = append(constraints, pk)
+	}
+	for _, col := range table.Columns {
+		if col.Unique {
+			constraints = append(constraints, []string{col.Name})
+		}
+	}
+	constraints = append(constraints, table.Unique...)
+
+	u := &uniqueIndex{
+		table:       table,
+		constraints: constraints,
+		ids:         make(map[string]bool, len(rows)),
+		keys:        make([]map[string]bool, len(constraints)),
+	}
+	for i := range u.keys {
+		u.keys[i] = make(map[string]bool, len(rows))
+	}
+
+	for _, row := range rows {
+		if id, ok := row["id"].(string); ok && skip[id] {
+			continue
+		}
+		u.record(row)
+	}
+	return u
+}
+
+// add returns an error if row duplicates an indexed ID, unique column value
+// or multi-column unique tuple; otherwise it indexes the row
+func (u *uniqueIndex) add(row Row) error {
+	if id, ok := row["id"].(string); ok && u.ids[id] {
+		return fmt.Errorf("row with id %s already exists in table %s", id, u.table.Name)
+	}
+
+	for i, cols := range u.constraints {
+		want, ok := uniqueKey(row, cols)
+		if !ok || !u.keys[i][want] {
+			continue
+		}
+		if len(cols) == 1 {
+			return fmt.Errorf("duplicate value %v for unique column %s in table %s", row[cols[0]], cols[0], u.table.Name)
+		}
+		values := make([]string, len(cols))
+		for j, col := range cols {
+			values[j] = fmt.Sprintf("%v", row[col])
+		}
+		return fmt.Errorf("duplicate value (%s) for unique columns (%s) in table %s",
+			strings.Join(values, ", "), strings.Join(cols, ", "), u.table.Name)
+	}
+
+	u.record(row)
+	return nil
+}
+
+func (u *uniqueIndex) record(row Row) {
+	if id, ok := row["id"].(string); ok {
+		u.ids[id] = true
+	}
+	for i, cols := range u.constraints {
+		if key, ok := uniqueKey(row, cols); ok {
+			u.keys[i][key] = true
+		}
+	}
+}
+
+// uniqueKey encodes a row's values for the given columns as a comparable key.
+// Each value is length-prefixed, since values may contain any byte and a plain
+// separator could let two different tuples collide. It returns false if any of
+// the values is NULL.
+func uniqueKey(row Row, cols []string) (string, bool) {
+	var key strings.Builder
+	for _, col := range cols {
+		val, ok := row[col]
+		if !ok || val == nil {
+			return "", false
+		}
+		s := fmt.Sprintf("%v", val)
+		fmt.Fprintf(&key, "%d:%s", len(s), s)
+	}
+	return key.String(), true
 }
 
 // Delete deletes a row by ID
 func (d *DataStore) Delete(tableName, id string) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	mu := d.lockFor(tableName)
+	mu.Lock()
+	defer mu.Unlock()
 
 	if !d.schema.TableExists(tableName) {
 		return fmt.Errorf("table %s does not exist", tableName)
@@ -306,10 +558,59 @@ func (d *DataStore) Delete(tableName, id string) error {
 	return d.writeAllRows(tableName, newRows)
 }
 
+// DeleteBatch deletes multiple rows by ID with a single rewrite of the table file.
+// IDs that do not exist are ignored. Returns the number of rows removed.
+func (d *DataStore) DeleteBatch(tableName string, ids []string) (int, error) {
+	mu := d.lockFor(tableName)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !d.schema.TableExists(tableName) {
+		return 0, fmt.Errorf("table %s does not exist", tableName)
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	idSet := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		idSet[id] = struct{}{}
+	}
+
+	rows, err := d.readAllRows(tableName)
+	if err != nil {
+		return 0, err
+	}
+
+	// Filter out every row in the batch
+	newRows := make([]Row, 0, len(rows))
+	for _, row := range rows {
+		if id, ok := row["id"].(string); ok {
+			if _, found := idSet[id]; found {
+				continue
+			}
+		}
+		newRows = append(newRows, row)
+	}
+
+	deleted := len(rows) - len(newRows)
+	if deleted == 0 {
+		return 0, nil
+	}
+
+	if err := d.writeAllRows(tableName, newRows); err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
 // Scan returns all rows in a table
 func (d *DataStore) Scan(tableName string) ([]Row, error) {
-	d.mu.RLock()
-	defer d.mu.RUnlock()
+	mu := d.lockFor(tableName)
+	mu.RLock()
+	defer mu.RUnlock()
 
 	if !d.schema.TableExists(tableName) {
 		return nil, fmt.Errorf("table %s does not exist", tableName)
@@ -318,10 +619,25 @@ func (d *DataStore) Scan(tableName string) ([]Row, error) {
 	return d.readAllRows(tableName)
 }
 
+// ScanFunc calls fn for each row in a table without loading the whole table
+// into memory. Scanning stops at the first error returned by fn.
+func (d *DataStore) ScanFunc(tableName string, fn func(Row) error) error {
+	mu := d.lockFor(tableName)
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if !d.schema.TableExists(tableName) {
+		return fmt.Errorf("table %s does not exist", tableName)
+	}
+
+	return d.eachRow(tableName, fn)
+}
+
 // Count returns the number of rows in a table
 func (d *DataStore) Count(tableName string) (int, error) {
-	d.mu.RLock()
-	defer d.mu.RUnlock()
+	mu := d.lockFor(tableName)
+	mu.RLock()
+	defer mu.RUnlock()
 
 	if !d.schema.TableExists(tableName) {
 		return 0, fmt.Errorf("table %s does not exist", tableName)
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func setupTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	return store
+}
+
+func TestInsert_CompositeUniqueSeparator(t *testing.T) {
+	store := setupTestStore(t)
+
+	store.Schema.CreateTable(&Table{
+		Name: "pairs",
+		Columns: []Column{
+			{Name: "id", Type: "text", PrimaryKey: true},
+			{Name: "a", Type: "text"},
+			{Name: "b", Type: "text"},
+		},
+		Unique: [][]string{{"a", "b"}},
+	})
+
+	// Values containing the display separator must not collide
+	if _, err := store.Data.Insert("pairs", Row{"id": "p1", "a": "x, y", "b": "z"}); err != nil {
+		t.Fatalf("Insert p1 failed: %v", err)
+	}
+	if _, err := store.Data.Insert("pairs", Row{"id": "p2", "a": "x", "b": "y, z"}); err != nil {
+		t.Fatalf("Insert p2 falsely violated unique constraint: %v", err)
+	}
+
+	// Nor must values containing NUL, which SQL literals and JSON can carry
+	if _, err := store.Data.Insert("pairs", Row{"id": "p4", "a": "p\x00q", "b": "r"}); err != nil {
+		t.Fatalf("Insert p4 failed: %v", err)
+	}
+	if _, err := store.Data.Insert("pairs", Row{"id": "p5", "a": "p", "b": "q\x00r"}); err != nil {
+		t.Fatalf("Insert p5 falsely violated unique constraint: %v", err)
+	}
+
+	_, err := store.Data.Insert("pairs", Row{"id": "p3", "a": "x", "b": "y, z"})
+	if err == nil || !strings.Contains(err.Error(), "duplicate value (x, y, z)") {
+		t.Fatalf("Expected composite unique violation, got %v", err)
+	}
+}
+
+func TestInsertBatch_Unique(t *testing.T) {
+	store := setupTestStore(t)
+
+	store.Schema.CreateTable(&Table{
+		Name: "users",
+		Columns: []Column{
+			{Name: "id", Type: "text", PrimaryKey: true},
+			{Name: "email", Type: "text", Unique: true},
+		},
+	})
+
+	rows := make([]Row, 5000)
+	for i := range rows {
+		rows[i] = Row{"id": fmt.Sprintf("u%d", i), "email": fmt.Sprintf("u%d@example.com", i)}
+	}
+	if _, err := store.Data.InsertBatch("users", rows); err != nil {
+		t.Fatalf("InsertBatch failed: %v", err)
+	}
+
+	// Conflicts with stored rows and with earlier rows in the same batch
+	for _, batch := range [][]Row{
+		{{"id": "u0"}},
+		{{"email": "u1@example.com"}},
+		{{"id": "a", "email": "a@example.com"}, {"id": "b", "email": "a@example.com"}},
+		{{"id": "c"}, {"id": "c"}},
+	} {
+		if _, err := store.Data.InsertBatch("users", batch); err == nil {
+			t.Errorf("Expected error for batch %v", batch)
+		}
+	}
+
+	count, _ := store.Data.Count("users")
+	if count != len(rows) {
+		t.Errorf("Expected %d rows, got %d", len(rows), count)
+	}
+}
+
+func TestInsert_CompositePrimaryKey(t *testing.T) {
+	store := setupTestStore(t)
+
+	store.Schema.CreateTable(&Table{
+		Name: "memberships",
+		Columns: []Column{
+			{Name: "id", Type: "text"},
+			{Name: "org_id", Type: "text", PrimaryKey: true},
+			{Name: "user_id", Type: "text", PrimaryKey: true},
+		},
+	})
+
+	// Key columns repeat individually; only the pair must be unique
+	for _, row := range []Row{
+		{"org_id": "o1", "user_id": "u1"},
+		{"org_id": "o1", "user_id": "u2"},
+		{"org_id": "o2", "user_id": "u1"},
+	} {
+		if _, err := store.Data.Insert("memberships", row); err != nil {
+			t.Fatalf("Insert %v failed: %v", row, err)
+		}
+	}
+
+	_, err := store.Data.Insert("memberships", Row{"org_id": "o1", "user_id": "u1"})
+	if err == nil || !strings.Contains(err.Error(), "duplicate value (o1, u1) for unique columns (org_id, user_id)") {
+		t.Fatalf("Expected composite key violation, got %v", err)
+	}
+}
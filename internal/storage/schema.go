@@ -4,8 +4,10 @@ package storage
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 )
 
@@ -19,10 +21,46 @@ type Column struct {
 	Default    string `json:"default,omitempty"`
 }
 
+// IsNumeric reports whether the column has a numeric type, so its values
+// compare as numbers rather than as text
+func (c Column) IsNumeric() bool {
+	switch strings.ToLower(c.Type) {
+	case "int", "integer", "smallint", "tinyint", "mediumint", "bigint",
+		"int2", "int4", "int8", "serial", "bigserial",
+		"decimal", "numeric", "real", "float", "float4", "float8", "double", "double precision":
+		return true
+	}
+	return false
+}
+
 // Table represents a table schema
 type Table struct {
 	Name    string   `json:"name"`
 	Columns []Column `json:"columns"`
+	// Unique lists multi-column unique constraints, e.g. [["tenant_id", "username"]]
+	Unique [][]string `json:"unique,omitempty"`
+}
+
+// PrimaryKey returns the primary key columns. When several columns are
+// marked PrimaryKey they form one composite key, as in PRIMARY KEY (a, b).
+func (t *Table) PrimaryKey() []string {
+	var cols []string
+	for _, col := range t.Columns {
+		if col.PrimaryKey {
+			cols = append(cols, col.Name)
+		}
+	}
+	return cols
+}
+
+// Column returns the named column, if the table has one
+func (t *Table) Column(name string) (Column, bool) {
+	for _, col := range t.Columns {
+		if col.Name == name {
+			return col, true
+		}
+	}
+	return Column{}, false
 }
 
 // SchemaStore manages table schemas as JSON files
@@ -30,6 +68,7 @@ type SchemaStore struct {
 	dataDir string
 	mu      sync.RWMutex
 	cache   map[string]*Table
+	fsync   bool
 }
 
 // NewSchemaStore creates a new schema store
@@ -115,16 +154,12 @@ func (s *SchemaStore) CreateTable(table *Table) error {
 		return fmt.Errorf("marshal schema: %w", err)
 	}
 
-	schemaPath := s.schemaPath(table.Name)
-	tempPath := schemaPath + ".tmp"
-
-	if err := os.WriteFile(tempPath, data, 0644); err != nil {
-		return fmt.Errorf("write temp file: %w", err)
-	}
-
-	if err := os.Rename(tempPath, schemaPath); err != nil {
-		os.Remove(tempPath)
-		return fmt.Errorf("rename temp file: %w", err)
+	err = writeFileAtomic(s.schemaPath(table.Name), s.fsync, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("write schema: %w", err)
 	}
 
 	// Create data directory for table
@@ -1,5 +1,22 @@
 package storage
 
+// DefaultLockStripes is the number of lock stripes used when none is set
+const DefaultLockStripes = 16
+
+// StoreOptions tunes concurrency and durability of a store
+type StoreOptions struct {
+	// LockStripes is the number of locks table writes are spread across.
+	// Tables hashing to different stripes can be written concurrently.
+	// Defaults to DefaultLockStripes.
+	LockStripes int
+
+	// Fsync flushes every write, and the directory entry of the renamed
+	// file, to disk before it returns. Without it a power loss or kernel
+	// crash can lose recent writes (a process crash can't), but writes are
+	// several times faster.
+	Fsync bool
+}
+
 // Store combines schema and data storage
 type Store struct {
 	Schema *SchemaStore
@@ -8,12 +25,18 @@ type Store struct {
 
 // NewStore creates a new combined store
 func NewStore(dataDir string) (*Store, error) {
+	return NewStoreWithOptions(dataDir, StoreOptions{})
+}
+
+// NewStoreWithOptions creates a combined store tuned by opts
+func NewStoreWithOptions(dataDir string, opts StoreOptions) (*Store, error) {
 	schema, err := NewSchemaStore(dataDir)
 	if err != nil {
 		return nil, err
 	}
+	schema.fsync = opts.Fsync
 
-	data := NewDataStore(dataDir, schema)
+	data := NewDataStoreWithOptions(dataDir, schema, opts)
 
 	return &Store{
 		Schema: schema,
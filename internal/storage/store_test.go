@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestNewStoreWithOptions(t *testing.T) {
+	store, err := NewStoreWithOptions(t.TempDir(), StoreOptions{LockStripes: 4, Fsync: true})
+	if err != nil {
+		t.Fatalf("NewStoreWithOptions failed: %v", err)
+	}
+	if len(store.Data.locks) != 4 || !store.Data.fsync {
+		t.Errorf("Options not applied: %d stripes, fsync %v", len(store.Data.locks), store.Data.fsync)
+	}
+
+	// Zero values fall back to the defaults
+	store, err = NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	if len(store.Data.locks) != DefaultLockStripes || store.Data.fsync {
+		t.Errorf("Expected defaults, got %d stripes, fsync %v", len(store.Data.locks), store.Data.fsync)
+	}
+}
+
+func TestDataStore_ConcurrentTables(t *testing.T) {
+	store, err := NewStoreWithOptions(t.TempDir(), StoreOptions{LockStripes: 2, Fsync: true})
+	if err != nil {
+		t.Fatalf("NewStoreWithOptions failed: %v", err)
+	}
+
+	const tables, rowsPerTable = 4, 25
+	for i := 0; i < tables; i++ {
+		store.Schema.CreateTable(&Table{
+			Name:    fmt.Sprintf("t%d", i),
+			Columns: []Column{{Name: "id", Type: "text", PrimaryKey: true}},
+		})
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < tables; i++ {
+		for j := 0; j < rowsPerTable; j++ {
+			wg.Add(1)
+			go func(table string) {
+				defer wg.Done()
+				if _, err := store.Data.Insert(table, Row{}); err != nil {
+					t.Errorf("Insert into %s failed: %v", table, err)
+				}
+			}(fmt.Sprintf("t%d", i))
+		}
+	}
+	wg.Wait()
+
+	// No write may be lost to a concurrent rewrite of the same file
+	for i := 0; i < tables; i++ {
+		count, err := store.Data.Count(fmt.Sprintf("t%d", i))
+		if err != nil {
+			t.Fatalf("Count failed: %v", err)
+		}
+		if count != rowsPerTable {
+			t.Errorf("Table t%d: expected %d rows, got %d", i, rowsPerTable, count)
+		}
+	}
+}